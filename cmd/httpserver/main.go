@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"httpfromtcp/internal/accesslog"
+	"httpfromtcp/internal/metrics"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/server"
 	"io"
@@ -14,9 +16,13 @@ import (
 
 const port = 42069
 
+var registry = metrics.NewRegistry()
+
 func main() {
 	srv := &server.Server{
-		Handler: testHandler,
+		Handler:   testHandler,
+		AccessLog: accesslog.New(os.Stdout, accesslog.CombinedFormat{}),
+		Observer:  registry,
 	}
 
 	_, err := srv.Serve(port)
@@ -40,7 +46,9 @@ func main() {
 func testHandler(w io.Writer, req *request.Request) *server.HandlerError {
 	path := req.RequestLine.RequestTarget
 
-	if strings.HasSuffix(path, "/yourproblem") {
+	if path == "/metrics" {
+		return registry.Handler(w, req)
+	} else if strings.HasSuffix(path, "/yourproblem") {
 		return &server.HandlerError{
 			StatusCode: 400,
 			Message:    "Your problem is not my problem\n",
@@ -2,7 +2,10 @@ module httpfromtcp
 
 go 1.24.0
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/crypto v0.45.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
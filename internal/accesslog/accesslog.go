@@ -0,0 +1,64 @@
+package accesslog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+)
+
+// Entry defines the data recorded for a single completed request
+type Entry struct {
+	Time   time.Time
+	Method string
+	Target string
+	Proto  string
+	Status int
+	Bytes  int
+	// RequestBytes is the size of the request body as received, before any Content-Encoding was decoded
+	RequestBytes int
+	Duration     time.Duration
+	RemoteAddr   string
+	UserAgent    string
+	Referer      string
+	RequestID    string
+}
+
+// Formatter writes a single Entry to w in a given log format
+type Formatter interface {
+	Format(w io.Writer, e Entry) error
+}
+
+// Logger writes access log entries to an output writer using a Formatter
+type Logger struct {
+	mu        sync.Mutex
+	out       io.Writer
+	formatter Formatter
+}
+
+// New creates a Logger writing to out, defaulting to the Common Log Format
+func New(out io.Writer, formatter Formatter) *Logger {
+	if formatter == nil {
+		formatter = CommonFormat{}
+	}
+	return &Logger{
+		out:       out,
+		formatter: formatter,
+	}
+}
+
+// Log writes an entry, serialising concurrent writers so lines don't interleave
+func (l *Logger) Log(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.formatter.Format(l.out, e)
+}
+
+// NewRequestID generates a random 16 byte hex encoded request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
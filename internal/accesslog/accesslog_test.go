@@ -0,0 +1,79 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func testEntry() Entry {
+	return Entry{
+		Time:         time.Date(2024, time.March, 7, 13, 55, 36, 0, time.UTC),
+		Method:       "GET",
+		Target:       "/coffee",
+		Proto:        "1.1",
+		Status:       200,
+		Bytes:        15,
+		Duration:     1500 * time.Microsecond,
+		RemoteAddr:   "127.0.0.1:52314",
+		UserAgent:    "curl/7.81.0",
+		RequestID:    "abc123",
+		RequestBytes: 42,
+	}
+}
+
+func TestFormats(t *testing.T) {
+	// Test: Common Log Format
+	buf := &bytes.Buffer{}
+	l := New(buf, CommonFormat{})
+	require.NoError(t, l.Log(testEntry()))
+	assert.Equal(t, "127.0.0.1 - - [07/Mar/2024:13:55:36 +0000] \"GET /coffee HTTP/1.1\" 200 15\n", buf.String())
+
+	// Test: Default formatter is Common Log Format
+	buf.Reset()
+	l = New(buf, nil)
+	require.NoError(t, l.Log(testEntry()))
+	assert.Equal(t, "127.0.0.1 - - [07/Mar/2024:13:55:36 +0000] \"GET /coffee HTTP/1.1\" 200 15\n", buf.String())
+
+	// Test: Combined Log Format with missing referer and zero bytes
+	buf.Reset()
+	l = New(buf, CombinedFormat{})
+	e := testEntry()
+	e.Bytes = 0
+	require.NoError(t, l.Log(e))
+	assert.Equal(t, "127.0.0.1 - - [07/Mar/2024:13:55:36 +0000] \"GET /coffee HTTP/1.1\" 200 - \"-\" \"curl/7.81.0\" \"abc123\"\n", buf.String())
+
+	// Test: Quotes and control characters in client supplied fields are escaped
+	buf.Reset()
+	e = testEntry()
+	e.Target = "/a\" 200 1 \"x"
+	e.Referer = "http://x/\"\n127.0.0.1"
+	e.UserAgent = "evil\\\x00"
+	require.NoError(t, l.Log(e))
+	assert.Equal(t, `127.0.0.1 - - [07/Mar/2024:13:55:36 +0000] "GET /a\" 200 1 \"x HTTP/1.1" 200 15 "http://x/\"\n127.0.0.1" "evil\\\x00" "abc123"`+"\n", buf.String())
+
+	// Test: JSON format
+	buf.Reset()
+	l = New(buf, JSONFormat{})
+	require.NoError(t, l.Log(testEntry()))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "request", decoded["msg"])
+	assert.Equal(t, "GET", decoded["method"])
+	assert.Equal(t, "/coffee", decoded["target"])
+	assert.Equal(t, float64(200), decoded["status"])
+	assert.Equal(t, float64(15), decoded["bytes"])
+	assert.Equal(t, float64(42), decoded["request_bytes"])
+	assert.Equal(t, "127.0.0.1:52314", decoded["remote_addr"])
+	assert.Equal(t, "abc123", decoded["request_id"])
+}
+
+func TestNewRequestID(t *testing.T) {
+	a := NewRequestID()
+	b := NewRequestID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,100 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+)
+
+// clfTimeLayout is the timestamp layout used by Common and Combined formats
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// CommonFormat writes entries in the NCSA Common Log Format
+type CommonFormat struct{}
+
+// CombinedFormat writes entries in the NCSA Combined Log Format
+type CombinedFormat struct{}
+
+// JSONFormat writes entries as JSON objects via log/slog
+type JSONFormat struct{}
+
+// Format writes e as `host - - [time] "request" status bytes`
+func (CommonFormat) Format(w io.Writer, e Entry) error {
+	_, err := io.WriteString(w, commonLine(e)+"\n")
+	return err
+}
+
+// Format writes e in Common Log Format followed by the referer, user agent and request ID
+func (CombinedFormat) Format(w io.Writer, e Entry) error {
+	line := fmt.Sprintf("%s %s %s %s\n",
+		commonLine(e),
+		quote(e.Referer),
+		quote(e.UserAgent),
+		quote(e.RequestID),
+	)
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+// Format writes e as a single JSON object per line
+func (JSONFormat) Format(w io.Writer, e Entry) error {
+	handler := slog.NewJSONHandler(w, nil)
+	record := slog.NewRecord(e.Time, slog.LevelInfo, "request", 0)
+	record.AddAttrs(
+		slog.String("method", e.Method),
+		slog.String("target", e.Target),
+		slog.String("proto", e.Proto),
+		slog.Int("status", e.Status),
+		slog.Int("bytes", e.Bytes),
+		slog.Int("request_bytes", e.RequestBytes),
+		slog.Duration("duration", e.Duration),
+		slog.String("remote_addr", e.RemoteAddr),
+		slog.String("user_agent", e.UserAgent),
+		slog.String("request_id", e.RequestID),
+	)
+	return handler.Handle(context.Background(), record)
+}
+
+// commonLine builds the fields shared by the Common and Combined formats
+func commonLine(e Entry) string {
+	host := e.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		host = "-"
+	}
+
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.Itoa(e.Bytes)
+	}
+
+	return fmt.Sprintf("%s - - [%s] \"%s %s HTTP/%s\" %d %s",
+		host,
+		e.Time.Format(clfTimeLayout),
+		escape(e.Method),
+		escape(e.Target),
+		e.Proto,
+		e.Status,
+		size,
+	)
+}
+
+// quote wraps an escaped value in double quotes, using "-" for empty values
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return `"` + escape(s) + `"`
+}
+
+// escape escapes quotes, backslashes and control characters like %q without the outer
+// quotes, so client supplied values can't end a field or a line early
+func escape(s string) string {
+	q := strconv.Quote(s)
+	return q[1 : len(q)-1]
+}
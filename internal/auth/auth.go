@@ -0,0 +1,174 @@
+// Package auth provides middleware that authenticates requests from the Authorization header
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by ParseAuthorization
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrMalformed     = errors.New("malformed authorization header")
+)
+
+var (
+	tokenRegex   = regexp.MustCompile(`^[!#$%&'*+\-.^_` + "`" + `|~0-9a-zA-Z]+$`)
+	token68Regex = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*$`)
+)
+
+// Credentials is a parsed Authorization header (RFC 9110 Section 11.4). Either Token68
+// or Params is set, parameter names are lowercased
+type Credentials struct {
+	Scheme  string
+	Token68 string
+	Params  map[string]string
+}
+
+// ParseAuthorization parses an Authorization or Proxy-Authorization value
+func ParseAuthorization(value string) (Credentials, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+
+	scheme, rest, _ := strings.Cut(value, " ")
+	if !tokenRegex.MatchString(scheme) {
+		return Credentials{}, fmt.Errorf("%w: invalid scheme %q", ErrMalformed, scheme)
+	}
+	creds := Credentials{Scheme: scheme}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return creds, nil
+	}
+	if token68Regex.MatchString(rest) {
+		creds.Token68 = rest
+		return creds, nil
+	}
+
+	params, err := parseParams(rest)
+	if err != nil {
+		return Credentials{}, err
+	}
+	creds.Params = params
+	return creds, nil
+}
+
+// parseParams parses a comma separated list of name=value pairs, values may be quoted strings
+func parseParams(s string) (map[string]string, error) {
+	params := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%w: expected auth-param in %q", ErrMalformed, s)
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		if !tokenRegex.MatchString(name) {
+			return nil, fmt.Errorf("%w: invalid parameter name %q", ErrMalformed, name)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated quoted string", ErrMalformed)
+			}
+			value, s = b.String(), s[i+1:]
+		} else {
+			end := strings.IndexAny(s, ", \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+			if !tokenRegex.MatchString(value) {
+				return nil, fmt.Errorf("%w: invalid parameter value %q", ErrMalformed, value)
+			}
+		}
+
+		if _, dup := params[name]; dup {
+			return nil, fmt.Errorf("%w: duplicate parameter %q", ErrMalformed, name)
+		}
+		params[name] = value
+
+		s = strings.TrimLeft(s, " \t")
+		if s != "" && s[0] != ',' {
+			return nil, fmt.Errorf("%w: expected comma before %q", ErrMalformed, s)
+		}
+	}
+}
+
+// Challenge is a WWW-Authenticate challenge, the realm is written first and other params sorted
+type Challenge struct {
+	Scheme string
+	Realm  string
+	Params map[string]string
+}
+
+// String formats the challenge with quoted parameter values
+func (c Challenge) String() string {
+	var params []string
+	if c.Realm != "" {
+		params = append(params, "realm="+strconv.Quote(c.Realm))
+	}
+	names := make([]string, 0, len(c.Params))
+	for name := range c.Params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		params = append(params, name+"="+strconv.Quote(c.Params[name]))
+	}
+	if len(params) == 0 {
+		return c.Scheme
+	}
+	return c.Scheme + " " + strings.Join(params, ", ")
+}
+
+// Unauthorized sets the WWW-Authenticate challenge and returns a 401 HandlerError
+func Unauthorized(w io.Writer, challenge Challenge, message string) *server.HandlerError {
+	if rw, ok := w.(server.ResponseWriter); ok {
+		rw.Header().Add("WWW-Authenticate", challenge.String())
+	}
+	return &server.HandlerError{StatusCode: int(response.StatusUnauthorized), Message: message}
+}
+
+// Principal identifies an authenticated client
+type Principal struct {
+	Scheme  string // Authentication scheme that was used, e.g. "Basic"
+	Subject string // User name, key ID or token subject
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of req carrying p, for custom authentication middleware
+func WithPrincipal(req *request.Request, p Principal) *request.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, p))
+}
+
+// FromRequest returns the principal attached by the middleware in this package
+func FromRequest(req *request.Request) (Principal, bool) {
+	p, ok := req.Context().Value(contextKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicVerifier checks a user name and password
+type BasicVerifier interface {
+	VerifyBasic(user, password string) bool
+}
+
+// Basic requires HTTP Basic authentication (RFC 7617) checked by v
+func Basic(realm string, v BasicVerifier) server.Middleware {
+	challenge := Challenge{Scheme: "Basic", Realm: realm, Params: map[string]string{"charset": "UTF-8"}}
+	return func(next server.Handler) server.Handler {
+		return func(w io.Writer, req *request.Request) *server.HandlerError {
+			user, password, ok := BasicCredentials(req)
+			if !ok || !v.VerifyBasic(user, password) {
+				return Unauthorized(w, challenge, "Unauthorized")
+			}
+			return next(w, WithPrincipal(req, Principal{Scheme: "Basic", Subject: user}))
+		}
+	}
+}
+
+// BasicCredentials returns the user name and password of a Basic Authorization header
+func BasicCredentials(req *request.Request) (string, string, bool) {
+	return ParseBasic(req.Headers.Get("Authorization"))
+}
+
+// ParseBasic decodes Basic credentials from an Authorization or Proxy-Authorization value
+func ParseBasic(value string) (string, string, bool) {
+	creds, err := ParseAuthorization(value)
+	if err != nil || !strings.EqualFold(creds.Scheme, "Basic") || creds.Token68 == "" {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(creds.Token68)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// Users maps user names to plain text passwords, compared in constant time
+type Users map[string]string
+
+func (u Users) VerifyBasic(user, password string) bool {
+	// Compare digests so neither length nor content leaks through timing,
+	// and do the same work for unknown users
+	expected, known := u[user]
+	given := sha256.Sum256([]byte(password))
+	want := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(given[:], want[:]) == 1 && known
+}
+
+// Htpasswd verifies users against bcrypt hashes loaded from an Apache htpasswd file.
+// Every check pays the bcrypt cost, caching results would keep fast password digests in memory
+type Htpasswd struct {
+	hashes map[string][]byte
+}
+
+// dummyHash is compared against for unknown users so they take as long as known ones
+var dummyHash = []byte("$2a$10$rkA2xh3T6GnBHtNo5WNt8.pTngqV3gaWeoh8A/.HwvAMp3UGBQ/QC")
+
+// LoadHtpasswd reads an htpasswd file, only bcrypt ($2y$) entries are supported
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseHtpasswd(f)
+}
+
+// ParseHtpasswd reads htpasswd lines of the form "user:hash", skipping blanks and comments
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{hashes: map[string][]byte{}}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("htpasswd line %d: expected user:hash", n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("htpasswd line %d: only bcrypt hashes are supported: %w", n, err)
+		}
+		h.hashes[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *Htpasswd) VerifyBasic(user, password string) bool {
+	hash, known := h.hashes[user]
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
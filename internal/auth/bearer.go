@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"strings"
+)
+
+// TokenVerifier validates a bearer token and returns who it belongs to
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (Principal, error)
+}
+
+// TokenVerifierFunc adapts a function to a TokenVerifier
+type TokenVerifierFunc func(ctx context.Context, token string) (Principal, error)
+
+func (f TokenVerifierFunc) VerifyToken(ctx context.Context, token string) (Principal, error) {
+	return f(ctx, token)
+}
+
+// Bearer requires a bearer token (RFC 6750) accepted by v
+func Bearer(realm string, v TokenVerifier) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w io.Writer, req *request.Request) *server.HandlerError {
+			token, ok := BearerToken(req)
+			if !ok {
+				// A request without credentials gets a challenge but no error code
+				return Unauthorized(w, Challenge{Scheme: "Bearer", Realm: realm}, "Unauthorized")
+			}
+
+			p, err := v.VerifyToken(req.Context(), token)
+			if err != nil {
+				return Unauthorized(w, Challenge{
+					Scheme: "Bearer",
+					Realm:  realm,
+					Params: map[string]string{"error": "invalid_token", "error_description": err.Error()},
+				}, "Unauthorized")
+			}
+			if p.Scheme == "" {
+				p.Scheme = "Bearer"
+			}
+			return next(w, WithPrincipal(req, p))
+		}
+	}
+}
+
+// BearerToken returns the token of a Bearer Authorization header
+func BearerToken(req *request.Request) (string, bool) {
+	creds, err := ParseAuthorization(req.Headers.Get("Authorization"))
+	if err != nil || !strings.EqualFold(creds.Scheme, "Bearer") || creds.Token68 == "" {
+		return "", false
+	}
+	return creds.Token68, true
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HMACScheme is the Authorization scheme used for signed requests
+const HMACScheme = "HMAC-SHA256"
+
+// DefaultMaxSkew is how far a signed request's Date may be from the server clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// SignRequest signs req with key, covering the method, target, Date, the named headers
+// and the body digest. It sets Date if missing and writes the Authorization header:
+//
+//	Authorization: HMAC-SHA256 keyId="k1", headers="date host", signature="base64"
+func SignRequest(req *request.Request, keyID string, key []byte, signedHeaders ...string) {
+	if req.Headers.Get("Date") == "" {
+		req.Headers.Set("Date", time.Now().UTC().Format(headers.TimeFormat))
+	}
+
+	names := []string{"date"}
+	for _, name := range signedHeaders {
+		name = strings.ToLower(name)
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	signature := computeSignature(req, key, names)
+	req.Headers.Set("Authorization", fmt.Sprintf(`%s keyId="%s", headers="%s", signature="%s"`,
+		HMACScheme, keyID, strings.Join(names, " "), signature))
+}
+
+// HMACVerifier checks requests signed with SignRequest
+type HMACVerifier struct {
+	// Keys maps key IDs to shared secrets
+	Keys map[string][]byte
+	// RequiredHeaders must be covered by the signature in addition to Date
+	RequiredHeaders []string
+	// MaxSkew bounds the difference between Date and the server clock, zero uses DefaultMaxSkew
+	MaxSkew time.Duration
+}
+
+// Middleware rejects requests without a valid signature
+func (v *HMACVerifier) Middleware(next server.Handler) server.Handler {
+	required := []string{"date"}
+	for _, name := range v.RequiredHeaders {
+		required = append(required, strings.ToLower(name))
+	}
+	challenge := Challenge{Scheme: HMACScheme, Params: map[string]string{"headers": strings.Join(required, " ")}}
+
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		keyID, err := v.verify(req, required)
+		if err != nil {
+			return Unauthorized(w, challenge, err.Error())
+		}
+		return next(w, WithPrincipal(req, Principal{Scheme: HMACScheme, Subject: keyID}))
+	}
+}
+
+// verify checks the signature and freshness of req, returning the key ID that signed it
+func (v *HMACVerifier) verify(req *request.Request, required []string) (string, error) {
+	creds, err := ParseAuthorization(req.Headers.Get("Authorization"))
+	if err != nil || !strings.EqualFold(creds.Scheme, HMACScheme) {
+		return "", fmt.Errorf("missing %s signature", HMACScheme)
+	}
+	keyID, signature := creds.Params["keyid"], creds.Params["signature"]
+	key, ok := v.Keys[keyID]
+	if !ok || signature == "" {
+		return "", fmt.Errorf("unknown key or missing signature")
+	}
+
+	names := strings.Fields(strings.ToLower(creds.Params["headers"]))
+	for _, name := range required {
+		if !slices.Contains(names, name) {
+			return "", fmt.Errorf("signature must cover %s", name)
+		}
+	}
+
+	date, err := time.Parse(headers.TimeFormat, req.Headers.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Date header")
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	if skew := time.Since(date); skew > maxSkew || skew < -maxSkew {
+		return "", fmt.Errorf("request date is outside the allowed clock skew")
+	}
+
+	expected := computeSignature(req, key, names)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", fmt.Errorf("signature mismatch")
+	}
+	return keyID, nil
+}
+
+// computeSignature signs the canonical form of a request:
+//
+//	METHOD target
+//	name: value     (one line per signed header, in the listed order)
+//	base64(sha256(body))
+func computeSignature(req *request.Request, key []byte, names []string) string {
+	var b strings.Builder
+	b.WriteString(req.RequestLine.Method + " " + req.RequestLine.RequestTarget + "\n")
+	for _, name := range names {
+		b.WriteString(name + ": " + strings.TrimSpace(req.Headers.Get(name)) + "\n")
+	}
+	digest := sha256.Sum256(req.Body)
+	b.WriteString(base64.StdEncoding.EncodeToString(digest[:]))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,436 @@
+// Package client sends requests to HTTP/1.1 servers over plain TCP, reusing
+// keep-alive connections per host and following redirects
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for Client fields left at zero
+const (
+	DefaultDialTimeout    = 30 * time.Second
+	DefaultMaxRedirects   = 10
+	DefaultMaxIdlePerHost = 2
+	DefaultIdleTimeout    = 90 * time.Second
+)
+
+// Errors returned by Client.Do
+var (
+	ErrUnsupportedScheme     = errors.New("unsupported url scheme")
+	ErrMissingHost           = errors.New("request has no host")
+	ErrTooManyRedirects      = errors.New("stopped after too many redirects")
+	ErrResponseHeaderTimeout = errors.New("timeout awaiting response headers")
+	ErrBodyClosed            = errors.New("read on closed response body")
+)
+
+// Client sends requests and reads their responses. The zero value is ready to use
+// and a Client is safe for concurrent use
+type Client struct {
+	Timeout               time.Duration // Limits the whole exchange, redirects and reading the body included, zero means none
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration // Limits the wait for a response once the request is written, zero means none
+	MaxRedirects          int           // Negative returns redirects to the caller instead of following them
+	MaxIdlePerHost        int
+	IdleTimeout           time.Duration // How long an idle connection is kept for reuse
+	pool                  pool
+}
+
+// Response is a response read from a server. The caller must close Body, which
+// returns the connection for reuse once the body has been read to the end
+type Response struct {
+	StatusLine response.StatusLine
+	Headers    headers.Headers
+	Body       io.ReadCloser
+	Request    *request.Request // The request that was answered, the last one when redirects were followed
+	body       *body
+}
+
+// NewRequest builds a request for an http URL with the Host header set
+func NewRequest(ctx context.Context, method, rawURL string, body []byte) (*request.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrMissingHost, rawURL)
+	}
+
+	req := &request.Request{
+		RequestLine: request.RequestLine{
+			HttpVersion:   "1.1",
+			RequestTarget: u.RequestURI(),
+			Method:        method,
+		},
+		Headers: headers.NewHeaders(),
+		Body:    body,
+	}
+	req.Headers.Set("Host", u.Host)
+	return req.WithContext(ctx), nil
+}
+
+// Get fetches rawURL
+func (c *Client) Get(ctx context.Context, rawURL string) (*Response, error) {
+	req, err := NewRequest(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends req to the server named by its Host header, or by an absolute-form target,
+// and follows redirects up to MaxRedirects. The request's context cancels the exchange
+func (c *Client) Do(req *request.Request) (*Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+
+	maxRedirects := c.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	for redirects := 0; ; redirects++ {
+		resp, err := c.send(ctx, req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		location := resp.Headers.Get("Location")
+		if maxRedirects < 0 || location == "" || !isRedirect(resp.StatusLine.StatusCode) {
+			resp.body.release = cancel
+			return resp, nil
+		}
+		// Drain a little of the redirect body so the connection can be reused
+		_, _ = io.CopyN(io.Discard, resp.Body, 4<<10)
+		_ = resp.Body.Close()
+		if redirects == maxRedirects {
+			cancel()
+			return nil, fmt.Errorf("%w: %d", ErrTooManyRedirects, maxRedirects)
+		}
+
+		req, err = redirect(req, resp.StatusLine.StatusCode, location)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+}
+
+// CloseIdleConnections closes connections kept for reuse
+func (c *Client) CloseIdleConnections() {
+	c.pool.closeIdle()
+}
+
+// send performs a single exchange, retrying once per stale pooled connection when
+// the server closed it before answering
+func (c *Client) send(ctx context.Context, req *request.Request) (*Response, error) {
+	u, err := requestURL(req)
+	if err != nil {
+		return nil, err
+	}
+	addr := address(u)
+
+	for {
+		pc, err := c.conn(ctx, addr)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		resp, retry, err := c.roundTrip(ctx, pc, req)
+		if err == nil {
+			return resp, nil
+		}
+		_ = pc.conn.Close()
+		if !retry || ctx.Err() != nil {
+			return nil, contextError(ctx, err)
+		}
+	}
+}
+
+// conn returns an idle connection to addr or dials a new one
+func (c *Client) conn(ctx context.Context, addr string) (*persistConn, error) {
+	idleTimeout := c.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	if pc := c.pool.get(addr, idleTimeout); pc != nil {
+		return pc, nil
+	}
+
+	dialTimeout := c.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &persistConn{conn: conn, br: bufio.NewReader(conn), addr: addr}, nil
+}
+
+// roundTrip writes req to pc and reads the head of the final response. retry reports
+// that a reused connection failed before any response arrived, so a new one may be tried
+func (c *Client) roundTrip(ctx context.Context, pc *persistConn, req *request.Request) (resp *Response, retry bool, err error) {
+	// Unblock any pending I/O when the context ends, until the body is closed
+	stop := context.AfterFunc(ctx, func() {
+		_ = pc.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if err != nil {
+			stop()
+		}
+	}()
+
+	if _, err := req.WriteTo(pc.conn); err != nil {
+		return nil, pc.reused && idempotent(req.RequestLine.Method), err
+	}
+
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	if c.ResponseHeaderTimeout > 0 {
+		timer = time.AfterFunc(c.ResponseHeaderTimeout, func() {
+			timedOut.Store(true)
+			_ = pc.conn.SetReadDeadline(time.Unix(1, 0))
+		})
+		defer timer.Stop()
+	}
+
+	if _, err := pc.br.Peek(1); err != nil {
+		if timedOut.Load() {
+			return nil, false, ErrResponseHeaderTimeout
+		}
+		return nil, pc.reused && idempotent(req.RequestLine.Method), err
+	}
+
+	// Interim 1xx responses are skipped, 101 Switching Protocols is final
+	var sl response.StatusLine
+	var h headers.Headers
+	for {
+		sl, h, err = response.ReadHead(pc.br)
+		if err != nil {
+			if timedOut.Load() {
+				return nil, false, ErrResponseHeaderTimeout
+			}
+			return nil, false, err
+		}
+		if sl.StatusCode >= 200 || sl.StatusCode == response.StatusSwitchingProtocols {
+			break
+		}
+	}
+	// A timer that already fired has left a deadline that would break reading the body
+	if timer != nil && !timer.Stop() {
+		return nil, false, ErrResponseHeaderTimeout
+	}
+
+	method := req.RequestLine.Method
+	r, err := response.NewBodyReader(pc.br, method, sl.StatusCode, h)
+	if err != nil {
+		return nil, false, err
+	}
+
+	b := &body{r: r, pc: pc, pool: &c.pool, ctx: ctx, stop: stop, reusable: reusable(req, sl, h)}
+	b.maxIdle = c.MaxIdlePerHost
+	if b.maxIdle == 0 {
+		b.maxIdle = DefaultMaxIdlePerHost
+	}
+	if n, _ := response.ContentLength(h); !response.HasBody(method, sl.StatusCode) || n == 0 {
+		b.eof = true
+	}
+
+	return &Response{StatusLine: sl, Headers: h, Body: b, Request: req, body: b}, false, nil
+}
+
+// reusable reports whether the connection can carry another request once the body is read
+func reusable(req *request.Request, sl response.StatusLine, h headers.Headers) bool {
+	if sl.HttpVersion != "1.1" || sl.StatusCode == response.StatusSwitchingProtocols {
+		return false
+	}
+	if hasToken(req.Headers.Get("Connection"), "close") || hasToken(h.Get("Connection"), "close") {
+		return false
+	}
+	if !response.HasBody(req.RequestLine.Method, sl.StatusCode) {
+		return true
+	}
+	if te := h.Get("Transfer-Encoding"); te != "" {
+		codings := strings.Split(te, ",")
+		return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+	}
+	// A body without Content-Length ends when the connection closes
+	n, err := response.ContentLength(h)
+	return err == nil && n >= 0
+}
+
+// body reads a response body and hands its connection back to the pool on Close
+type body struct {
+	r        io.Reader
+	pc       *persistConn
+	pool     *pool
+	maxIdle  int
+	ctx      context.Context
+	stop     func() bool
+	release  func()
+	reusable bool
+	eof      bool
+	closed   bool
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyClosed
+	}
+	if b.eof {
+		return 0, io.EOF
+	}
+	n, err := b.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.eof = true
+		return n, io.EOF
+	}
+	if err != nil {
+		err = contextError(b.ctx, err)
+	}
+	return n, err
+}
+
+// Close releases the connection, keeping it for reuse if the body was read to the end
+func (b *body) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	stopped := b.stop()
+	if b.release != nil {
+		b.release()
+	}
+	if b.eof && b.reusable && stopped {
+		b.pool.put(b.pc, b.maxIdle)
+		return nil
+	}
+	return b.pc.conn.Close()
+}
+
+// redirect builds the request that follows a redirect response (RFC 9110 Section 15.4).
+// 301, 302 and 303 switch to GET without a body, 307 and 308 repeat the request
+func redirect(req *request.Request, statusCode response.StatusCode, location string) (*request.Request, error) {
+	base, err := requestURL(req)
+	if err != nil {
+		return nil, err
+	}
+	u, err := base.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("%w: redirect to %q", ErrUnsupportedScheme, u.String())
+	}
+
+	method, body := req.RequestLine.Method, req.Body
+	h := maps.Clone(req.Headers)
+	if statusCode <= response.StatusSeeOther && method != "GET" && method != "HEAD" {
+		method, body = "GET", nil
+		h.Del("Content-Type")
+	}
+	// Credentials are only sent back to the host they were meant for
+	if u.Hostname() != base.Hostname() {
+		h.Del("Authorization")
+		h.Del("Proxy-Authorization")
+		h.Del("Cookie")
+	}
+	h.Set("Host", u.Host)
+
+	next := &request.Request{
+		RequestLine: request.RequestLine{
+			HttpVersion:   "1.1",
+			RequestTarget: u.RequestURI(),
+			Method:        method,
+		},
+		Headers: h,
+		Body:    body,
+	}
+	return next.WithContext(req.Context()), nil
+}
+
+// requestURL reconstructs the URL req is for
+func requestURL(req *request.Request) (*url.URL, error) {
+	target := req.RequestLine.RequestTarget
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+		}
+		return u, nil
+	}
+
+	host := req.Headers.Get("Host")
+	if host == "" {
+		return nil, ErrMissingHost
+	}
+	return url.Parse("http://" + host + target)
+}
+
+// address returns host:port for u, defaulting to port 80
+func address(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func isRedirect(statusCode response.StatusCode) bool {
+	switch statusCode {
+	case response.StatusMovedPermanently, response.StatusFound, response.StatusSeeOther,
+		response.StatusTemporaryRedirect, response.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// idempotent reports whether a request may be sent again (RFC 9110 Section 9.2.2)
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// hasToken reports whether a comma separated header value lists token
+func hasToken(value, token string) bool {
+	for _, v := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextError prefers the context's error once it has ended, since I/O then fails
+// with a deadline error that hides the cause
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
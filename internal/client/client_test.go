@@ -0,0 +1,294 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServer serves handler on a random local port and returns its address
+func startServer(t *testing.T, handler server.Handler) string {
+	t.Helper()
+	srv := &server.Server{Handler: handler}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	return fmt.Sprintf("127.0.0.1:%d", srv.Listener.Addr().(*net.TCPAddr).Port)
+}
+
+// startRaw calls serve for every accepted connection and counts the connections
+func startRaw(t *testing.T, serve func(conn net.Conn)) (string, *atomic.Int32) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	accepted := &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	return l.Addr().String(), accepted
+}
+
+// keepAlive answers every request on a connection with its position on that connection
+func keepAlive(conn net.Conn) {
+	for i := 1; ; i++ {
+		if _, err := request.RequestFromReader(conn); err != nil {
+			return
+		}
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\n%d", i)
+	}
+}
+
+// readAll reads and closes a response body
+func readAll(t *testing.T, resp *Response) string {
+	t.Helper()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return string(b)
+}
+
+func TestClient_Do(t *testing.T) {
+	addr := startServer(t, func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw := w.(server.ResponseWriter)
+		rw.Header().Set("X-Host", req.Headers.Get("Host"))
+		if req.RequestLine.RequestTarget == "/stream" {
+			_, _ = rw.Write([]byte("first "))
+			_ = rw.Flush()
+			_, _ = rw.Write([]byte("second"))
+			return nil
+		}
+		fmt.Fprintf(w, "%s %s %s", req.RequestLine.Method, req.RequestLine.RequestTarget, req.Body)
+		return nil
+	})
+	c := &Client{}
+	ctx := context.Background()
+
+	// Test: GET with a Content-Length body
+	resp, err := c.Get(ctx, "http://"+addr+"/path?q=1")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", resp.StatusLine.HttpVersion)
+	assert.EqualValues(t, 200, resp.StatusLine.StatusCode)
+	assert.Equal(t, "OK", resp.StatusLine.ReasonPhrase)
+	assert.Equal(t, addr, resp.Headers.Get("X-Host"))
+	assert.Equal(t, "GET /path?q=1 ", readAll(t, resp))
+
+	// Test: The request body is sent with a Content-Length
+	req, err := NewRequest(ctx, "POST", "http://"+addr+"/submit", []byte("hello"))
+	require.NoError(t, err)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /submit hello", readAll(t, resp))
+
+	// Test: Chunked bodies are decoded
+	resp, err = c.Get(ctx, "http://"+addr+"/stream")
+	require.NoError(t, err)
+	assert.Equal(t, "chunked", resp.Headers.Get("Transfer-Encoding"))
+	assert.Equal(t, "first second", readAll(t, resp))
+
+	// Test: HEAD responses have no body
+	req, err = NewRequest(ctx, "HEAD", "http://"+addr+"/", nil)
+	require.NoError(t, err)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	assert.NotEqual(t, "0", resp.Headers.Get("Content-Length"))
+	assert.Equal(t, "", readAll(t, resp))
+
+	// Test: Only http URLs are supported
+	_, err = c.Get(ctx, "https://"+addr+"/")
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+}
+
+func TestClient_KeepAlive(t *testing.T) {
+	ctx := context.Background()
+
+	// Test: Sequential requests share one connection once bodies are read
+	addr, accepted := startRaw(t, keepAlive)
+	c := &Client{}
+	for _, want := range []string{"1", "2", "3"} {
+		resp, err := c.Get(ctx, "http://"+addr+"/")
+		require.NoError(t, err)
+		assert.Equal(t, want, readAll(t, resp))
+	}
+	assert.EqualValues(t, 1, accepted.Load())
+	assert.Equal(t, 1, c.pool.count(addr))
+
+	// Test: A body closed before its end discards the connection
+	resp, err := c.Get(ctx, "http://"+addr+"/")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, 0, c.pool.count(addr))
+	resp, err = c.Get(ctx, "http://"+addr+"/")
+	require.NoError(t, err)
+	assert.Equal(t, "1", readAll(t, resp))
+	assert.EqualValues(t, 2, accepted.Load())
+
+	// Test: CloseIdleConnections empties the pool
+	c.CloseIdleConnections()
+	assert.Equal(t, 0, c.pool.count(addr))
+
+	// Test: A pooled connection the server closed is retried on a new one
+	addr, accepted = startRaw(t, func(conn net.Conn) {
+		if _, err := request.RequestFromReader(conn); err == nil {
+			fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		}
+	})
+	c = &Client{}
+	for range 2 {
+		resp, err := c.Get(ctx, "http://"+addr+"/")
+		require.NoError(t, err)
+		assert.Equal(t, "ok", readAll(t, resp))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.EqualValues(t, 2, accepted.Load())
+
+	// Test: Close-delimited bodies are read to EOF and never pooled
+	addr, _ = startRaw(t, func(conn net.Conn) {
+		if _, err := request.RequestFromReader(conn); err == nil {
+			fmt.Fprint(conn, "HTTP/1.1 200 OK\r\n\r\nuntil close")
+		}
+	})
+	resp, err = c.Get(ctx, "http://"+addr+"/")
+	require.NoError(t, err)
+	assert.Equal(t, "until close", readAll(t, resp))
+	assert.Equal(t, 0, c.pool.count(addr))
+
+	// Test: Connection: close from the server prevents reuse
+	addr, _ = startRaw(t, func(conn net.Conn) {
+		if _, err := request.RequestFromReader(conn); err == nil {
+			fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 2\r\n\r\nok")
+		}
+	})
+	resp, err = c.Get(ctx, "http://"+addr+"/")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", readAll(t, resp))
+	assert.Equal(t, 0, c.pool.count(addr))
+}
+
+func TestClient_Redirects(t *testing.T) {
+	addr := startServer(t, func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw := w.(server.ResponseWriter)
+		switch req.RequestLine.RequestTarget {
+		case "/found":
+			rw.Header().Set("Location", "/target")
+			rw.WriteHeader(302)
+		case "/temporary":
+			rw.Header().Set("Location", "target?from=307")
+			rw.WriteHeader(307)
+		case "/loop":
+			rw.Header().Set("Location", "/loop")
+			rw.WriteHeader(301)
+		default:
+			fmt.Fprintf(w, "%s %s %s auth=%s", req.RequestLine.Method, req.RequestLine.RequestTarget, req.Body, req.Headers.Get("Authorization"))
+		}
+		return nil
+	})
+	c := &Client{}
+	ctx := context.Background()
+
+	// Test: 302 after POST is followed with a GET and no body, keeping same-host credentials
+	req, err := NewRequest(ctx, "POST", "http://"+addr+"/found", []byte("data"))
+	require.NoError(t, err)
+	req.Headers.Set("Authorization", "Bearer t")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET /target  auth=Bearer t", readAll(t, resp))
+	assert.Equal(t, "/target", resp.Request.RequestLine.RequestTarget)
+
+	// Test: 307 repeats the method and body against a relative Location
+	req, err = NewRequest(ctx, "POST", "http://"+addr+"/temporary", []byte("data"))
+	require.NoError(t, err)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /target?from=307 data auth=", readAll(t, resp))
+
+	// Test: The redirect limit stops loops
+	c.MaxRedirects = 3
+	_, err = c.Get(ctx, "http://"+addr+"/loop")
+	assert.ErrorIs(t, err, ErrTooManyRedirects)
+
+	// Test: A negative limit hands redirects to the caller
+	c.MaxRedirects = -1
+	resp, err = c.Get(ctx, "http://"+addr+"/found")
+	require.NoError(t, err)
+	assert.EqualValues(t, 302, resp.StatusLine.StatusCode)
+	assert.Equal(t, "/target", resp.Headers.Get("Location"))
+	require.NoError(t, resp.Body.Close())
+
+	// Test: Credentials are dropped when redirected to another host
+	other := startServer(t, func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw := w.(server.ResponseWriter)
+		rw.Header().Set("Location", "http://"+addr+"/target")
+		rw.WriteHeader(302)
+		return nil
+	})
+	c.MaxRedirects = 0
+	req, err = NewRequest(ctx, "GET", "http://localhost:"+portOf(t, other)+"/", nil)
+	require.NoError(t, err)
+	req.Headers.Set("Authorization", "Bearer t")
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET /target  auth=", readAll(t, resp))
+}
+
+func TestClient_Timeouts(t *testing.T) {
+	stall := make(chan struct{})
+	t.Cleanup(func() { close(stall) })
+	addr, _ := startRaw(t, func(conn net.Conn) {
+		req, err := request.RequestFromReader(conn)
+		if err != nil {
+			return
+		}
+		if req.RequestLine.RequestTarget == "/partial" {
+			fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\npart")
+		}
+		<-stall
+	})
+
+	// Test: Cancelling the context aborts a request awaiting its response
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := (&Client{}).Get(ctx, "http://"+addr+"/")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Test: ResponseHeaderTimeout bounds the wait for the head
+	_, err = (&Client{ResponseHeaderTimeout: 50 * time.Millisecond}).Get(context.Background(), "http://"+addr+"/")
+	assert.ErrorIs(t, err, ErrResponseHeaderTimeout)
+
+	// Test: Timeout also covers reading the body
+	resp, err := (&Client{Timeout: 100 * time.Millisecond}).Get(context.Background(), "http://"+addr+"/partial")
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	assert.Equal(t, "part", string(b))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, resp.Body.Close())
+	_, err = resp.Body.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrBodyClosed)
+}
+
+// portOf returns the port of a host:port address
+func portOf(t *testing.T, addr string) string {
+	t.Helper()
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	return port
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+// persistConn is a connection that may carry several requests in turn
+type persistConn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	addr      string
+	reused    bool
+	idleSince time.Time
+}
+
+// pool keeps idle connections per host:port for keep-alive reuse
+type pool struct {
+	mu   sync.Mutex
+	idle map[string][]*persistConn
+}
+
+// get returns the most recently used idle connection to addr that hasn't expired
+func (p *pool) get(addr string, idleTimeout time.Duration) *persistConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.idle[addr]
+	for len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if idleTimeout > 0 && time.Since(pc.idleSince) > idleTimeout {
+			_ = pc.conn.Close()
+			continue
+		}
+		p.idle[addr] = conns
+		pc.reused = true
+		return pc
+	}
+	delete(p.idle, addr)
+	return nil
+}
+
+// put returns a connection for reuse, closing it if addr already has maxIdle waiting
+func (p *pool) put(pc *persistConn, maxIdle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idle == nil {
+		p.idle = map[string][]*persistConn{}
+	}
+	if len(p.idle[pc.addr]) >= maxIdle {
+		_ = pc.conn.Close()
+		return
+	}
+	_ = pc.conn.SetDeadline(time.Time{})
+	pc.idleSince = time.Now()
+	p.idle[pc.addr] = append(p.idle[pc.addr], pc)
+}
+
+// closeIdle closes every idle connection
+func (p *pool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conns := range p.idle {
+		for _, pc := range conns {
+			_ = pc.conn.Close()
+		}
+	}
+	p.idle = nil
+}
+
+// count returns the number of idle connections to addr
+func (p *pool) count(addr string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle[addr])
+}
@@ -0,0 +1,143 @@
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"strings"
+)
+
+// DefaultMinSize is the smallest buffered body worth compressing
+const DefaultMinSize = 1024
+
+// supportedEncodings lists the content codings we can produce, in order of preference
+var supportedEncodings = []string{"gzip", "deflate"}
+
+// DefaultContentTypes are the media types compressed when Compressor.ContentTypes is nil.
+// Entries ending in "/" match every subtype
+var DefaultContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"image/svg+xml",
+}
+
+// Compressor compresses response bodies for clients that accept gzip or deflate
+type Compressor struct {
+	// MinSize skips buffered bodies smaller than this many bytes, streamed bodies are always compressed
+	MinSize int
+	// Level is the compression level passed to compress/gzip and compress/zlib, zero uses the default
+	Level int
+	// ContentTypes lists eligible media types, already compressed types such as images should not be included
+	ContentTypes []string
+}
+
+// New creates a Compressor with DefaultMinSize, the default level and DefaultContentTypes
+func New() *Compressor {
+	return &Compressor{
+		MinSize:      DefaultMinSize,
+		Level:        gzip.DefaultCompression,
+		ContentTypes: DefaultContentTypes,
+	}
+}
+
+// Middleware compresses the responses of next according to the request's Accept-Encoding
+func (c *Compressor) Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw, ok := w.(server.ResponseWriter)
+		if !ok {
+			return next(w, req)
+		}
+
+		cw := &compressWriter{
+			w:          rw,
+			compressor: c,
+			encoding:   NegotiateEncoding(req.Headers.Get("Accept-Encoding")),
+			status:     200,
+		}
+		handlerErr := next(cw, req)
+		if handlerErr != nil {
+			return handlerErr
+		}
+		return cw.close()
+	}
+}
+
+// NegotiateEncoding picks the preferred supported coding from an Accept-Encoding value,
+// returning "" when the response should not be compressed
+func NegotiateEncoding(acceptEncoding string) string {
+	best := ""
+	bestQ := 0.0
+	wildcardQ := -1.0
+	listed := map[string]bool{}
+
+	for _, qv := range headers.ParseQualityValues(acceptEncoding) {
+		coding := strings.ToLower(qv.Value)
+		listed[coding] = true
+		if coding == "*" {
+			wildcardQ = qv.Q
+			continue
+		}
+		for _, supported := range supportedEncodings {
+			if coding == supported && qv.Q > bestQ {
+				best, bestQ = coding, qv.Q
+			}
+		}
+	}
+
+	// A wildcard covers any coding not listed explicitly
+	if wildcardQ > bestQ {
+		for _, supported := range supportedEncodings {
+			if !listed[supported] {
+				return supported
+			}
+		}
+	}
+	return best
+}
+
+// eligible reports whether a Content-Type is in the compressible list.
+// An empty type is the server's text/plain default
+func (c *Compressor) eligible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+
+	types := c.ContentTypes
+	if types == nil {
+		types = DefaultContentTypes
+	}
+	for _, t := range types {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEncoder creates the writer for a negotiated coding
+func (c *Compressor) newEncoder(encoding string, w io.Writer) (encoder, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	// HTTP's deflate coding is the zlib format (RFC 9110 Section 8.4.1.2)
+	if encoding == "deflate" {
+		return zlib.NewWriterLevel(w, level)
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// encoder is implemented by both gzip.Writer and zlib.Writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
@@ -0,0 +1,162 @@
+package compression
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var largeBody = strings.Repeat("all good, frfr\n", 200)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "gzip", NegotiateEncoding("gzip, deflate"))
+	assert.Equal(t, "deflate", NegotiateEncoding("gzip;q=0.5, deflate"))
+	assert.Equal(t, "deflate", NegotiateEncoding("br, deflate"))
+	assert.Equal(t, "", NegotiateEncoding("gzip;q=0, identity"))
+	assert.Equal(t, "", NegotiateEncoding(""))
+	assert.Equal(t, "gzip", NegotiateEncoding("*"))
+	assert.Equal(t, "deflate", NegotiateEncoding("gzip;q=0, *;q=0.5"))
+}
+
+// rawResponse is a parsed raw HTTP response
+type rawResponse struct {
+	status  string
+	headers map[string]string
+	body    []byte
+}
+
+// do sends a raw request to addr and parses the response, decoding chunked bodies
+func do(t *testing.T, addr, raw string) rawResponse {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, raw)
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	status, err := br.ReadString('\n')
+	require.NoError(t, err)
+	resp := rawResponse{status: strings.TrimSpace(status), headers: map[string]string{}}
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\r\n" {
+			break
+		}
+		k, v, _ := strings.Cut(strings.TrimSpace(line), ": ")
+		resp.headers[k] = v
+	}
+
+	if resp.headers["Transfer-Encoding"] != "chunked" {
+		resp.body, err = io.ReadAll(br)
+		require.NoError(t, err)
+		return resp
+	}
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+		require.NoError(t, err)
+		chunk := make([]byte, size+2)
+		_, err = io.ReadFull(br, chunk)
+		require.NoError(t, err)
+		if size == 0 {
+			return resp
+		}
+		resp.body = append(resp.body, chunk[:size]...)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	c := New()
+	srv := &server.Server{
+		Handler: c.Middleware(func(w io.Writer, req *request.Request) *server.HandlerError {
+			rw := w.(server.ResponseWriter)
+			switch req.RequestLine.RequestTarget {
+			case "/small":
+				_, _ = rw.Write([]byte("tiny"))
+			case "/png":
+				rw.Header().Set("Content-Type", "image/png")
+				_, _ = rw.Write([]byte(largeBody))
+			case "/empty":
+				rw.WriteHeader(204)
+			case "/stream":
+				rw.Header().Set("ETag", `"v1"`)
+				_, _ = rw.Write([]byte("first "))
+				_ = rw.Flush()
+				_, _ = rw.Write([]byte("second"))
+			default:
+				_, _ = rw.Write([]byte(largeBody))
+			}
+			return nil
+		}),
+	}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// Test: Large text body is gzipped with a matching Content-Length
+	resp := do(t, addr, "GET / HTTP/1.1\r\nAccept-Encoding: gzip, deflate\r\n\r\n")
+	assert.Equal(t, "gzip", resp.headers["Content-Encoding"])
+	assert.Equal(t, "Accept-Encoding", resp.headers["Vary"])
+	assert.Equal(t, strconv.Itoa(len(resp.body)), resp.headers["Content-Length"])
+	assert.Less(t, len(resp.body), len(largeBody))
+	gz, err := gzip.NewReader(strings.NewReader(string(resp.body)))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, largeBody, string(decoded))
+
+	// Test: Deflate when preferred
+	resp = do(t, addr, "GET / HTTP/1.1\r\nAccept-Encoding: gzip;q=0.1, deflate\r\n\r\n")
+	assert.Equal(t, "deflate", resp.headers["Content-Encoding"])
+	zr, err := zlib.NewReader(strings.NewReader(string(resp.body)))
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, largeBody, string(decoded))
+
+	// Test: No Accept-Encoding still varies on it
+	resp = do(t, addr, "GET / HTTP/1.1\r\n\r\n")
+	assert.Equal(t, "", resp.headers["Content-Encoding"])
+	assert.Equal(t, "Accept-Encoding", resp.headers["Vary"])
+	assert.Equal(t, largeBody, string(resp.body))
+
+	// Test: Bodies under the threshold are left alone
+	resp = do(t, addr, "GET /small HTTP/1.1\r\nAccept-Encoding: gzip\r\n\r\n")
+	assert.Equal(t, "", resp.headers["Content-Encoding"])
+	assert.Equal(t, "tiny", string(resp.body))
+
+	// Test: Already compressed types are skipped
+	resp = do(t, addr, "GET /png HTTP/1.1\r\nAccept-Encoding: gzip\r\n\r\n")
+	assert.Equal(t, "", resp.headers["Content-Encoding"])
+	assert.Equal(t, "", resp.headers["Vary"])
+
+	// Test: 204 is never compressed
+	resp = do(t, addr, "GET /empty HTTP/1.1\r\nAccept-Encoding: gzip\r\n\r\n")
+	assert.Equal(t, "HTTP/1.1 204 No Content", resp.status)
+	assert.Equal(t, "", resp.headers["Content-Encoding"])
+
+	// Test: Streamed responses are compressed chunk by chunk with a weakened ETag
+	resp = do(t, addr, "GET /stream HTTP/1.1\r\nAccept-Encoding: gzip\r\n\r\n")
+	assert.Equal(t, "chunked", resp.headers["Transfer-Encoding"])
+	assert.Equal(t, "gzip", resp.headers["Content-Encoding"])
+	assert.Equal(t, `W/"v1"`, resp.headers["ETag"])
+	gz, err = gzip.NewReader(strings.NewReader(string(resp.body)))
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(decoded))
+}
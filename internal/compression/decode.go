@@ -0,0 +1,118 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxDecodedSize caps decoded request bodies when Decoder.MaxSize is zero
+const DefaultMaxDecodedSize = 10 << 20
+
+// Errors returned by DecodeBody
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	ErrBodyTooLarge        = errors.New("decoded body too large")
+)
+
+// Decoder decodes gzip and deflate request bodies before they reach a handler.
+// Apply its Middleware only to the routes that should accept compressed uploads
+type Decoder struct {
+	// MaxSize limits the decoded body in bytes, protecting against decompression bombs
+	MaxSize int64
+}
+
+// NewDecoder creates a Decoder with the given decoded size limit
+func NewDecoder(maxSize int64) *Decoder {
+	return &Decoder{MaxSize: maxSize}
+}
+
+// Middleware replaces the request body with its decoded form, removing Content-Encoding
+// and recording the received size in EncodedBodyLength. Unsupported codings get 415,
+// bodies over the limit 413 and corrupt data 400
+func (d *Decoder) Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		contentEncoding := req.Headers.Get("Content-Encoding")
+		if contentEncoding == "" {
+			return next(w, req)
+		}
+
+		maxSize := d.MaxSize
+		if maxSize <= 0 {
+			maxSize = DefaultMaxDecodedSize
+		}
+
+		decoded, err := DecodeBody(req.Body, contentEncoding, maxSize)
+		switch {
+		case errors.Is(err, ErrUnsupportedEncoding):
+			// Tell the client which codings would have worked (RFC 9110 Section 15.5.16)
+			if rw, ok := w.(server.ResponseWriter); ok {
+				rw.Header().Set("Accept-Encoding", strings.Join(supportedEncodings, ", "))
+			}
+			return &server.HandlerError{StatusCode: 415, Message: fmt.Sprintf("%v\n", err)}
+		case errors.Is(err, ErrBodyTooLarge):
+			return &server.HandlerError{StatusCode: 413, Message: fmt.Sprintf("%v\n", err)}
+		case err != nil:
+			return &server.HandlerError{StatusCode: 400, Message: fmt.Sprintf("Error decoding body: %v\n", err)}
+		}
+
+		req.EncodedBodyLength = len(req.Body)
+		req.Body = decoded
+		req.Headers.Del("Content-Encoding")
+		req.Headers.Set("Content-Length", strconv.Itoa(len(decoded)))
+		return next(w, req)
+	}
+}
+
+// DecodeBody undoes the codings listed in a Content-Encoding value, last applied first,
+// failing with ErrBodyTooLarge once the output would exceed maxSize bytes
+func DecodeBody(body []byte, contentEncoding string, maxSize int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		var r io.Reader
+		switch coding {
+		case "identity", "":
+			continue
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			r = gz
+		case "deflate":
+			r = deflateReader(body)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+		}
+
+		// Read one byte past the limit to tell "exactly at" from "over"
+		decoded, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(decoded)) > maxSize {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxSize)
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// deflateReader reads the zlib format, falling back to raw deflate which some clients send instead
+func deflateReader(body []byte) io.Reader {
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return flate.NewReader(bytes.NewReader(body))
+	}
+	return zr
+}
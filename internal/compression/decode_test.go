@@ -0,0 +1,115 @@
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	// Test: gzip
+	decoded, err := DecodeBody(gzipBytes(t, `{"a":1}`), "gzip", 100)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(decoded))
+
+	// Test: zlib wrapped deflate
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	_, _ = zw.Write([]byte("zlib data"))
+	require.NoError(t, zw.Close())
+	decoded, err = DecodeBody(buf.Bytes(), "deflate", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "zlib data", string(decoded))
+
+	// Test: Raw deflate
+	buf.Reset()
+	fw, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	_, _ = fw.Write([]byte("raw data"))
+	require.NoError(t, fw.Close())
+	decoded, err = DecodeBody(buf.Bytes(), "deflate", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "raw data", string(decoded))
+
+	// Test: Stacked codings are undone in reverse order
+	twice := gzipBytes(t, string(gzipBytes(t, "nested")))
+	decoded, err = DecodeBody(twice, "gzip, identity, gzip", 100)
+	require.NoError(t, err)
+	assert.Equal(t, "nested", string(decoded))
+
+	// Test: Decompression bomb is cut off at the limit
+	_, err = DecodeBody(gzipBytes(t, strings.Repeat("0", 1<<20)), "gzip", 1024)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Exactly at the limit is allowed
+	_, err = DecodeBody(gzipBytes(t, strings.Repeat("0", 1024)), "gzip", 1024)
+	assert.NoError(t, err)
+
+	// Test: Unsupported coding
+	_, err = DecodeBody([]byte("x"), "br", 100)
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+
+	// Test: Corrupt data
+	_, err = DecodeBody([]byte("not gzip"), "gzip", 100)
+	assert.Error(t, err)
+}
+
+func TestDecoderMiddleware(t *testing.T) {
+	var seen *request.Request
+	h := NewDecoder(64).Middleware(func(w io.Writer, req *request.Request) *server.HandlerError {
+		seen = req
+		return nil
+	})
+
+	newRequest := func(encoding string, body []byte) *request.Request {
+		raw := "POST /upload HTTP/1.1\r\nContent-Encoding: " + encoding + "\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + string(body)
+		req, err := request.RequestFromReader(strings.NewReader(raw))
+		require.NoError(t, err)
+		return req
+	}
+
+	// Test: Body is decoded and the encoded length kept
+	body := gzipBytes(t, `{"name":"coffee"}`)
+	herr := h(response.NewWriter(io.Discard), newRequest("gzip", body))
+	require.Nil(t, herr)
+	assert.Equal(t, `{"name":"coffee"}`, string(seen.Body))
+	assert.Equal(t, len(body), seen.EncodedBodyLength)
+	assert.Equal(t, "", seen.Headers.Get("Content-Encoding"))
+	assert.Equal(t, "17", seen.Headers.Get("Content-Length"))
+
+	// Test: Unsupported coding gets 415 advertising what is accepted
+	w := response.NewWriter(io.Discard)
+	herr = h(w, newRequest("br", []byte("x")))
+	require.NotNil(t, herr)
+	assert.Equal(t, 415, herr.StatusCode)
+	assert.Equal(t, "gzip, deflate", w.Header().Get("Accept-Encoding"))
+
+	// Test: Oversized decoded body gets 413
+	herr = h(response.NewWriter(io.Discard), newRequest("gzip", gzipBytes(t, strings.Repeat("a", 65))))
+	require.NotNil(t, herr)
+	assert.Equal(t, 413, herr.StatusCode)
+
+	// Test: Corrupt body gets 400
+	herr = h(response.NewWriter(io.Discard), newRequest("gzip", []byte("garbage")))
+	require.NotNil(t, herr)
+	assert.Equal(t, 400, herr.StatusCode)
+}
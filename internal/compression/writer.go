@@ -0,0 +1,122 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"net"
+	"strings"
+)
+
+// compressWriter holds back the response until it knows whether to compress it
+type compressWriter struct {
+	w          server.ResponseWriter
+	compressor *Compressor
+	encoding   string
+	status     int
+	buf        bytes.Buffer
+	decided    bool
+	enc        encoder
+}
+
+func (cw *compressWriter) Header() headers.Headers {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if !cw.decided {
+		cw.status = statusCode
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	switch {
+	case !cw.decided:
+		return cw.buf.Write(p)
+	case cw.enc != nil:
+		return cw.enc.Write(p)
+	default:
+		return cw.w.Write(p)
+	}
+}
+
+// Flush commits to compressing a streamed body, then flushes the encoder's output
+func (cw *compressWriter) Flush() error {
+	if err := cw.decide(true); err != nil {
+		return err
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return cw.w.Flush()
+}
+
+// Hijack passes through to the server, the connection is never compressed
+func (cw *compressWriter) Hijack() (net.Conn, []byte, error) {
+	hj, ok := cw.w.(server.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("compression: response writer cannot be hijacked")
+	}
+	cw.decided = true
+	return hj.Hijack()
+}
+
+// decide chooses whether to compress, then forwards the status, headers and buffered body
+func (cw *compressWriter) decide(streaming bool) error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+
+	h := cw.w.Header()
+	status := response.StatusCode(cw.status)
+	typeEligible := cw.compressor.eligible(h.Get("Content-Type")) && h.Get("Content-Encoding") == ""
+
+	// The representation depends on Accept-Encoding whenever it could have been compressed
+	if typeEligible {
+		h.AddVary("Accept-Encoding")
+	}
+
+	compress := typeEligible &&
+		cw.encoding != "" &&
+		response.BodyAllowed(status) &&
+		status != response.StatusPartialContent &&
+		(streaming || cw.buf.Len() >= cw.compressor.MinSize)
+
+	cw.w.WriteHeader(cw.status)
+	if !compress {
+		_, err := cw.w.Write(cw.buf.Bytes())
+		return err
+	}
+
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	// The compressed bytes differ, so a strong validator no longer applies
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+
+	enc, err := cw.compressor.newEncoder(cw.encoding, cw.w)
+	if err != nil {
+		return err
+	}
+	cw.enc = enc
+	_, err = enc.Write(cw.buf.Bytes())
+	return err
+}
+
+// close sends the response once the handler has returned
+func (cw *compressWriter) close() *server.HandlerError {
+	err := cw.decide(false)
+	if err == nil && cw.enc != nil {
+		err = cw.enc.Close()
+	}
+	if err != nil {
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error compressing response: %v\n", err)}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"strings"
+	"time"
+)
+
+// Results of Evaluate, Proceed means the request should be served normally
+const (
+	Proceed            = 0
+	NotModified        = 304
+	PreconditionFailed = 412
+)
+
+// StrongETag computes a strong entity tag from the exact bytes of a representation
+func StrongETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// WeakETag computes a weak entity tag, for representations that are semantically
+// equivalent but not byte-for-byte identical, e.g. across compression
+func WeakETag(content []byte) string {
+	return "W/" + StrongETag(content)
+}
+
+// Evaluate checks the request's preconditions against the current ETag and Last-Modified
+// time of the selected representation, in the order of RFC 9110 Section 13.2.2.
+// An empty etag or zero lastModified means the validator is unavailable, the representation
+// itself is assumed to exist
+func Evaluate(req *request.Request, etag string, lastModified time.Time) int {
+	return evaluate(req, etag, lastModified, true)
+}
+
+// evaluate is Evaluate for a representation that may not exist, which only "*" can tell apart
+func evaluate(req *request.Request, etag string, lastModified time.Time, exists bool) int {
+	method := req.RequestLine.Method
+	h := req.Headers
+
+	// Steps 1 and 2: If-Match, or If-Unmodified-Since when If-Match is absent
+	if ifMatch := h.Get("If-Match"); ifMatch != "" {
+		if !matchesAny(ifMatch, etag, exists, strongMatch) {
+			return PreconditionFailed
+		}
+	} else if since, ok := parseDate(h.Get("If-Unmodified-Since")); ok && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return PreconditionFailed
+		}
+	}
+
+	// Steps 3 and 4: If-None-Match, or If-Modified-Since for GET and HEAD when it is absent
+	safe := method == "GET" || method == "HEAD"
+	if ifNoneMatch := h.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchesAny(ifNoneMatch, etag, exists, weakMatch) {
+			if safe {
+				return NotModified
+			}
+			return PreconditionFailed
+		}
+	} else if since, ok := parseDate(h.Get("If-Modified-Since")); ok && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return NotModified
+		}
+	}
+
+	return Proceed
+}
+
+// matchesAny reports whether a list of entity tags matches the current etag, or the list
+// is "*" and there is a current representation, with or without an etag
+func matchesAny(list, etag string, exists bool, match func(a, b string) bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return exists
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range splitETags(list) {
+		if match(candidate, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// strongMatch compares two entity tags, neither of which may be weak (RFC 9110 Section 8.8.3.2)
+func strongMatch(a, b string) bool {
+	return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+}
+
+// weakMatch compares two entity tags ignoring the weak indicator
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// splitETags splits a comma separated list of entity tags, which may themselves contain commas
+func splitETags(list string) []string {
+	var tags []string
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return tags
+		}
+
+		prefix := ""
+		if strings.HasPrefix(list, "W/") {
+			prefix, list = "W/", list[2:]
+		}
+		if !strings.HasPrefix(list, `"`) {
+			return tags
+		}
+		end := strings.IndexByte(list[1:], '"')
+		if end == -1 {
+			return tags
+		}
+		tags = append(tags, prefix+list[:end+2])
+		list = list[end+2:]
+	}
+}
+
+// parseDate parses an HTTP date, reporting whether it was valid
+func parseDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(headers.TimeFormat, value)
+	return t, err == nil
+}
@@ -0,0 +1,166 @@
+package conditional
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var lastModified = time.Date(2024, time.March, 7, 13, 55, 36, 0, time.UTC)
+
+func newRequest(t *testing.T, method string, hdrs ...string) *request.Request {
+	t.Helper()
+	raw := method + " / HTTP/1.1\r\n" + strings.Join(hdrs, "\r\n")
+	if len(hdrs) > 0 {
+		raw += "\r\n"
+	}
+	req, err := request.RequestFromReader(strings.NewReader(raw + "\r\n"))
+	require.NoError(t, err)
+	return req
+}
+
+func TestETags(t *testing.T) {
+	// Test: Strong tags are quoted and stable, weak tags are prefixed
+	etag := StrongETag([]byte("hello"))
+	assert.Equal(t, etag, StrongETag([]byte("hello")))
+	assert.NotEqual(t, etag, StrongETag([]byte("hello!")))
+	assert.True(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`))
+	assert.Equal(t, "W/"+etag, WeakETag([]byte("hello")))
+}
+
+func TestEvaluate(t *testing.T) {
+	etag := `"v1"`
+	before := lastModified.Add(-time.Hour).Format(headers.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(headers.TimeFormat)
+	exact := lastModified.Format(headers.TimeFormat)
+
+	// Test: No preconditions
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "GET"), etag, lastModified))
+
+	// Test: If-None-Match uses weak comparison and handles lists
+	assert.Equal(t, NotModified, Evaluate(newRequest(t, "GET", `If-None-Match: "v0", W/"v1"`), etag, lastModified))
+	assert.Equal(t, NotModified, Evaluate(newRequest(t, "HEAD", `If-None-Match: *`), etag, lastModified))
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "GET", `If-None-Match: "v0"`), etag, lastModified))
+
+	// Test: If-None-Match match on unsafe methods fails the precondition
+	assert.Equal(t, PreconditionFailed, Evaluate(newRequest(t, "PUT", `If-None-Match: "v1"`), etag, lastModified))
+
+	// Test: If-None-Match takes precedence over If-Modified-Since
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "GET", `If-None-Match: "v0"`, "If-Modified-Since: "+after), etag, lastModified))
+
+	// Test: If-Modified-Since
+	assert.Equal(t, NotModified, Evaluate(newRequest(t, "GET", "If-Modified-Since: "+exact), etag, lastModified))
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "GET", "If-Modified-Since: "+before), etag, lastModified))
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "POST", "If-Modified-Since: "+exact), etag, lastModified))
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "GET", "If-Modified-Since: yesterday"), etag, lastModified))
+
+	// Test: If-Match uses strong comparison
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "PUT", `If-Match: "v1"`), etag, lastModified))
+	assert.Equal(t, PreconditionFailed, Evaluate(newRequest(t, "PUT", `If-Match: W/"v1"`), etag, lastModified))
+
+	// Test: "*" matches any existing representation, even one without an ETag
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "PUT", `If-Match: *`), "", lastModified))
+	assert.Equal(t, PreconditionFailed, evaluate(newRequest(t, "PUT", `If-Match: *`), "", time.Time{}, false))
+	assert.Equal(t, PreconditionFailed, Evaluate(newRequest(t, "PUT", `If-None-Match: *`), "", time.Time{}))
+	assert.Equal(t, Proceed, evaluate(newRequest(t, "PUT", `If-None-Match: *`), "", time.Time{}, false))
+
+	// Test: If-Match takes precedence over If-Unmodified-Since
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "PUT", `If-Match: "v1"`, "If-Unmodified-Since: "+before), etag, lastModified))
+
+	// Test: If-Unmodified-Since
+	assert.Equal(t, PreconditionFailed, Evaluate(newRequest(t, "PUT", "If-Unmodified-Since: "+before), etag, lastModified))
+	assert.Equal(t, Proceed, Evaluate(newRequest(t, "PUT", "If-Unmodified-Since: "+exact), etag, lastModified))
+
+	// Test: If-Match is checked before If-None-Match
+	assert.Equal(t, PreconditionFailed, Evaluate(newRequest(t, "GET", `If-Match: "v0"`, `If-None-Match: "v1"`), etag, lastModified))
+}
+
+func TestMiddleware(t *testing.T) {
+	srv := &server.Server{
+		Handler: Middleware(func(w io.Writer, req *request.Request) *server.HandlerError {
+			rw := w.(server.ResponseWriter)
+			body := []byte("representation")
+			rw.Header().Set("ETag", StrongETag(body))
+			rw.Header().Set("Last-Modified", lastModified.Format(headers.TimeFormat))
+			rw.Header().Set("Cache-Control", "max-age=60")
+			rw.Header().Set("Content-Type", "text/html")
+			_, _ = rw.Write(body)
+			return nil
+		}),
+	}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+	etag := StrongETag([]byte("representation"))
+
+	// Test: Unconditional request gets the full response
+	out := send(t, addr, "GET / HTTP/1.1\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nrepresentation"))
+
+	// Test: Matching If-None-Match gets 304 with representation headers stripped
+	out = send(t, addr, "GET / HTTP/1.1\r\nIf-None-Match: "+etag+"\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 304 Not Modified\r\n"))
+	assert.Contains(t, out, "ETag: "+etag+"\r\n")
+	assert.Contains(t, out, "Cache-Control: max-age=60\r\n")
+	assert.NotContains(t, out, "Content-Type")
+	assert.NotContains(t, out, "Content-Length")
+	assert.NotContains(t, out, "Last-Modified")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\n"))
+
+	// Test: Failed If-Match gets 412
+	out = send(t, addr, "GET / HTTP/1.1\r\nIf-Match: \"stale\"\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 412 Precondition Failed\r\n"))
+	assert.True(t, strings.HasSuffix(out, "Precondition Failed\n"))
+}
+
+func TestGuard(t *testing.T) {
+	stored := "v1"
+	current := func(req *request.Request) (string, time.Time, bool) {
+		return StrongETag([]byte(stored)), lastModified, true
+	}
+	srv := &server.Server{
+		Handler: Guard(current)(func(w io.Writer, req *request.Request) *server.HandlerError {
+			stored = string(req.Body)
+			_, _ = w.Write([]byte("stored"))
+			return nil
+		}),
+	}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// Test: A failed If-Match is answered before the handler changes anything
+	out := send(t, addr, "PUT / HTTP/1.1\r\nIf-Match: \"stale\"\r\nContent-Length: 2\r\n\r\nv2")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 412 Precondition Failed\r\n"))
+	assert.Equal(t, "v1", stored)
+
+	// Test: A matching If-Match runs the handler
+	out = send(t, addr, "PUT / HTTP/1.1\r\nIf-Match: "+StrongETag([]byte("v1"))+"\r\nContent-Length: 2\r\n\r\nv2")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nstored"))
+	assert.Equal(t, "v2", stored)
+}
+
+// send writes a raw request to addr and returns the raw response
+func send(t *testing.T, addr, raw string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprint(conn, raw)
+	require.NoError(t, err)
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(out)
+}
@@ -0,0 +1,162 @@
+package conditional
+
+import (
+	"bytes"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"time"
+)
+
+// notModifiedHeaders are the representation headers removed from a 304 response (RFC 9110 Section 15.4.5)
+var notModifiedHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Range",
+	"Transfer-Encoding",
+	"Accept-Ranges",
+}
+
+// Middleware evaluates the preconditions of GET and HEAD requests against the ETag and
+// Last-Modified headers set by next, replacing a 2xx response with 304 Not Modified or
+// 412 Precondition Failed before any of its body is sent.
+// Other methods are passed through, as next has already changed the resource by the time
+// its headers are known; use Guard to check them before next runs
+func Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw, ok := w.(server.ResponseWriter)
+		method := req.RequestLine.Method
+		if !ok || (method != "GET" && method != "HEAD") {
+			return next(w, req)
+		}
+
+		pw := &preconditionWriter{w: rw, req: req, status: 200}
+		handlerErr := next(pw, req)
+		if handlerErr != nil {
+			return handlerErr
+		}
+		pw.decide()
+		return pw.err
+	}
+}
+
+// Validators returns the current ETag and Last-Modified time of the resource req targets,
+// empty or zero when unavailable, and whether the resource exists at all
+type Validators func(req *request.Request) (etag string, lastModified time.Time, exists bool)
+
+// Guard evaluates the preconditions of requests other than GET and HEAD against current
+// before next runs, answering 412 Precondition Failed without calling next when they fail
+func Guard(current Validators) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w io.Writer, req *request.Request) *server.HandlerError {
+			method := req.RequestLine.Method
+			if method == "GET" || method == "HEAD" {
+				return next(w, req)
+			}
+
+			etag, lastModified, exists := current(req)
+			if evaluate(req, etag, lastModified, exists) == PreconditionFailed {
+				return &server.HandlerError{StatusCode: PreconditionFailed, Message: "Precondition Failed\n"}
+			}
+			return next(w, req)
+		}
+	}
+}
+
+// preconditionWriter holds back the status and body until the preconditions are known
+type preconditionWriter struct {
+	w       server.ResponseWriter
+	req     *request.Request
+	status  int
+	body    bytes.Buffer
+	decided bool
+	discard bool                 // The response was replaced, drop anything else the handler writes
+	err     *server.HandlerError // Set when the response must be replaced with an error
+}
+
+func (pw *preconditionWriter) Header() headers.Headers {
+	return pw.w.Header()
+}
+
+func (pw *preconditionWriter) WriteHeader(statusCode int) {
+	if !pw.decided {
+		pw.status = statusCode
+	}
+}
+
+func (pw *preconditionWriter) Write(p []byte) (int, error) {
+	switch {
+	case pw.discard:
+		return len(p), nil
+	case pw.decided:
+		return pw.w.Write(p)
+	default:
+		return pw.body.Write(p)
+	}
+}
+
+// Flush decides the preconditions so the headers can be streamed
+func (pw *preconditionWriter) Flush() error {
+	pw.decide()
+	if pw.err != nil {
+		return fmt.Errorf("conditional: %s", pw.err.Message)
+	}
+	if pw.discard {
+		return nil
+	}
+	return pw.w.Flush()
+}
+
+// Hijack passes through to the server, skipping precondition handling entirely
+func (pw *preconditionWriter) Hijack() (net.Conn, []byte, error) {
+	hj, ok := pw.w.(server.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("conditional: response writer cannot be hijacked")
+	}
+	pw.decided = true
+	return hj.Hijack()
+}
+
+// decide evaluates the preconditions once and forwards the held back response
+func (pw *preconditionWriter) decide() {
+	if pw.decided {
+		return
+	}
+	pw.decided = true
+
+	if pw.status >= 200 && pw.status < 300 {
+		h := pw.w.Header()
+		lastModified, _ := parseDate(h.Get("Last-Modified"))
+
+		switch Evaluate(pw.req, h.Get("ETag"), lastModified) {
+		case NotModified:
+			for _, key := range notModifiedHeaders {
+				h.Del(key)
+			}
+			// Last-Modified only helps caches when there is no ETag
+			if h.Get("ETag") != "" {
+				h.Del("Last-Modified")
+			}
+			pw.w.WriteHeader(NotModified)
+			pw.discard = true
+			return
+		case PreconditionFailed:
+			pw.discard = true
+			for _, key := range notModifiedHeaders {
+				h.Del(key)
+			}
+			pw.err = &server.HandlerError{StatusCode: PreconditionFailed, Message: "Precondition Failed\n"}
+			return
+		}
+	}
+
+	pw.w.WriteHeader(pw.status)
+	if _, err := pw.w.Write(pw.body.Bytes()); err != nil {
+		pw.err = &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error writing response: %v\n", err)}
+	}
+}
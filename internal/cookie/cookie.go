@@ -0,0 +1,240 @@
+// Package cookie parses Cookie request headers and builds Set-Cookie values (RFC 6265)
+package cookie
+
+import (
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Get and Validate
+var (
+	ErrNoCookie     = errors.New("named cookie not present")
+	ErrInvalidName  = errors.New("invalid cookie name")
+	ErrInvalidValue = errors.New("invalid cookie value")
+	ErrInvalidAttr  = errors.New("invalid cookie attribute")
+)
+
+// SameSite is the value of the SameSite attribute, SameSiteDefault omits it
+type SameSite int
+
+const (
+	SameSiteDefault SameSite = iota
+	SameSiteLax
+	SameSiteStrict
+	SameSiteNone
+)
+
+// String returns the attribute value as written in Set-Cookie
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteLax:
+		return "Lax"
+	case SameSiteStrict:
+		return "Strict"
+	case SameSiteNone:
+		return "None"
+	default:
+		return ""
+	}
+}
+
+// Cookie is a cookie received in a Cookie header or sent in a Set-Cookie header.
+// Only Name and Value are set on parsed request cookies
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path    string
+	Domain  string
+	Expires time.Time
+	// MaxAge is the lifetime in seconds. Zero omits the attribute, negative deletes the cookie now
+	MaxAge      int
+	Secure      bool
+	HttpOnly    bool
+	SameSite    SameSite
+	Partitioned bool
+}
+
+// Parse splits a Cookie header value into cookies, skipping malformed pairs
+func Parse(header string) []*Cookie {
+	var cookies []*Cookie
+	for _, pair := range strings.Split(header, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || validName(name) != nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		if validValue(value) != nil {
+			continue
+		}
+		cookies = append(cookies, &Cookie{Name: name, Value: value})
+	}
+	return cookies
+}
+
+// Cookies returns the cookies sent with a request
+func Cookies(req *request.Request) []*Cookie {
+	return Parse(req.Headers.Get("Cookie"))
+}
+
+// Get returns the first cookie named name sent with a request
+func Get(req *request.Request, name string) (*Cookie, error) {
+	for _, c := range Cookies(req) {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoCookie, name)
+}
+
+// Set validates c and adds it as a separate Set-Cookie field line of h
+func Set(h headers.Headers, c *Cookie) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	h.Add("Set-Cookie", c.String())
+	return nil
+}
+
+// Validate reports whether c can be serialized into a valid Set-Cookie value
+func (c *Cookie) Validate() error {
+	if err := validName(c.Name); err != nil {
+		return err
+	}
+	if err := validValue(c.Value); err != nil {
+		return err
+	}
+	if strings.ContainsAny(c.Path, ";\r\n") || hasCTL(c.Path) {
+		return fmt.Errorf("%w: path %q", ErrInvalidAttr, c.Path)
+	}
+	if c.Domain != "" && !validDomain(c.Domain) {
+		return fmt.Errorf("%w: domain %q", ErrInvalidAttr, c.Domain)
+	}
+	if !c.Expires.IsZero() && c.Expires.Year() < 1601 {
+		return fmt.Errorf("%w: expires before 1601", ErrInvalidAttr)
+	}
+	if c.SameSite == SameSiteNone && !c.Secure {
+		return fmt.Errorf("%w: SameSite=None requires Secure", ErrInvalidAttr)
+	}
+	if c.Partitioned && !c.Secure {
+		return fmt.Errorf("%w: Partitioned requires Secure", ErrInvalidAttr)
+	}
+	if strings.HasPrefix(c.Name, "__Secure-") && !c.Secure {
+		return fmt.Errorf("%w: __Secure- prefix requires Secure", ErrInvalidAttr)
+	}
+	if strings.HasPrefix(c.Name, "__Host-") && (!c.Secure || c.Domain != "" || c.Path != "/") {
+		return fmt.Errorf("%w: __Host- prefix requires Secure, Path=/ and no Domain", ErrInvalidAttr)
+	}
+	return nil
+}
+
+// String serializes c for a Set-Cookie header, call Validate first for untrusted input
+func (c *Cookie) String() string {
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	if strings.ContainsAny(c.Value, " ,") {
+		b.WriteString(`"` + c.Value + `"`)
+	} else {
+		b.WriteString(c.Value)
+	}
+
+	if c.Path != "" {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" {
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + c.Expires.UTC().Format(headers.TimeFormat))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.SameSite != SameSiteDefault {
+		b.WriteString("; SameSite=" + c.SameSite.String())
+	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+	return b.String()
+}
+
+// validName checks a cookie name is a token (RFC 6265 Section 4.1.1)
+func validName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidName)
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	return nil
+}
+
+// validValue checks a cookie value uses cookie-octets. Spaces and commas are allowed
+// since String quotes them, as browsers accept
+func validValue(value string) error {
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch < 0x20 || ch >= 0x7f || ch == '"' || ch == ';' || ch == '\\' {
+			return fmt.Errorf("%w: %q", ErrInvalidValue, value)
+		}
+	}
+	return nil
+}
+
+// validDomain checks a domain attribute is a plausible host name
+func validDomain(domain string) bool {
+	domain = strings.TrimPrefix(domain, ".")
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			ch := label[i]
+			if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isTokenChar reports whether ch is a tchar (RFC 9110 Section 5.6.2)
+func isTokenChar(ch byte) bool {
+	if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", ch) >= 0
+}
+
+// hasCTL reports whether s contains control characters
+func hasCTL(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
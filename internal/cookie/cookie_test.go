@@ -0,0 +1,93 @@
+package cookie
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// Test: Pairs are split on semicolons, quotes are removed and bad pairs skipped
+	cookies := Parse(`session=abc123; theme="dark"; bad name=x; novalue; empty=`)
+	require.Len(t, cookies, 3)
+	assert.Equal(t, Cookie{Name: "session", Value: "abc123"}, *cookies[0])
+	assert.Equal(t, Cookie{Name: "theme", Value: "dark"}, *cookies[1])
+	assert.Equal(t, Cookie{Name: "empty", Value: ""}, *cookies[2])
+
+	// Test: Lookup on a request
+	req, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\nCookie: a=1; b=2\r\n\r\n"))
+	require.NoError(t, err)
+	c, err := Get(req, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "2", c.Value)
+	_, err = Get(req, "c")
+	assert.ErrorIs(t, err, ErrNoCookie)
+}
+
+func TestString(t *testing.T) {
+	// Test: Every attribute
+	c := &Cookie{
+		Name:        "id",
+		Value:       "a3fWa",
+		Path:        "/",
+		Domain:      ".example.com",
+		Expires:     time.Date(2026, 10, 21, 7, 28, 0, 0, time.UTC),
+		MaxAge:      3600,
+		Secure:      true,
+		HttpOnly:    true,
+		SameSite:    SameSiteNone,
+		Partitioned: true,
+	}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, "id=a3fWa; Path=/; Domain=example.com; Expires=Wed, 21 Oct 2026 07:28:00 GMT; Max-Age=3600; Secure; HttpOnly; SameSite=None; Partitioned", c.String())
+
+	// Test: Negative MaxAge deletes the cookie, spaces are quoted
+	c = &Cookie{Name: "id", Value: "a b", MaxAge: -1, SameSite: SameSiteLax}
+	assert.Equal(t, `id="a b"; Max-Age=0; SameSite=Lax`, c.String())
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cookie Cookie
+		err    error
+	}{
+		{Cookie{Name: "", Value: "x"}, ErrInvalidName},
+		{Cookie{Name: "a;b", Value: "x"}, ErrInvalidName},
+		{Cookie{Name: "a", Value: "x;y"}, ErrInvalidValue},
+		{Cookie{Name: "a", Value: "\"x"}, ErrInvalidValue},
+		{Cookie{Name: "a", Value: "x", Path: "/a;b"}, ErrInvalidAttr},
+		{Cookie{Name: "a", Value: "x", Domain: "bad domain"}, ErrInvalidAttr},
+		{Cookie{Name: "a", Value: "x", SameSite: SameSiteNone}, ErrInvalidAttr},
+		{Cookie{Name: "a", Value: "x", Partitioned: true}, ErrInvalidAttr},
+		{Cookie{Name: "__Host-a", Value: "x", Secure: true, Path: "/sub"}, ErrInvalidAttr},
+		{Cookie{Name: "__Host-a", Value: "x", Secure: true, Path: "/"}, nil},
+	}
+	for _, tt := range tests {
+		err := tt.cookie.Validate()
+		if tt.err == nil {
+			assert.NoError(t, err, tt.cookie.Name)
+		} else {
+			assert.ErrorIs(t, err, tt.err, tt.cookie.Name)
+		}
+	}
+}
+
+func TestSetWritesSeparateLines(t *testing.T) {
+	// Test: Several cookies become separate Set-Cookie lines on the wire
+	var out bytes.Buffer
+	w := response.NewWriter(&out)
+	require.NoError(t, Set(w.Header(), &Cookie{Name: "a", Value: "1", Expires: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}))
+	require.NoError(t, Set(w.Header(), &Cookie{Name: "b", Value: "2", HttpOnly: true}))
+	assert.Error(t, Set(w.Header(), &Cookie{Name: "bad name"}))
+	require.NoError(t, w.SendResponse())
+
+	raw := out.String()
+	assert.Contains(t, raw, "Set-Cookie: a=1; Expires=Thu, 01 Jan 2026 00:00:00 GMT\r\n")
+	assert.Contains(t, raw, "Set-Cookie: b=2; HttpOnly\r\n")
+	assert.Equal(t, []string{"a=1; Expires=Thu, 01 Jan 2026 00:00:00 GMT", "b=2; HttpOnly"}, w.Header().Values("set-cookie"))
+}
@@ -0,0 +1,194 @@
+// Package cors implements Cross-Origin Resource Sharing (Fetch Standard, "CORS protocol")
+package cors
+
+import (
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures which cross-origin requests are allowed
+type Options struct {
+	// AllowedOrigins lists exact origins such as "https://app.example.com", wildcard
+	// subdomains such as "https://*.example.com", or "*" for any origin without credentials
+	AllowedOrigins []string
+	// AllowedOriginPatterns are matched against the whole Origin value
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowedMethods defaults to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a preflight may ask for, "*" allows any
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge lets browsers cache preflight results, zero leaves it to the browser
+	MaxAge time.Duration
+}
+
+// CORS is a configured CORS middleware
+type CORS struct {
+	opts      Options
+	anyOrigin bool
+	exact     []string
+	wildcards [][2]string // scheme and host suffix, e.g. "https://" and ".example.com"
+	methods   []string
+	anyHeader bool
+	headers   []string
+	exposed   string
+	maxAge    string
+}
+
+// New validates opts and creates the middleware
+func New(opts Options) (*CORS, error) {
+	c := &CORS{opts: opts}
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "*")
+			if strings.Contains(host, "*") {
+				return nil, fmt.Errorf("cors: origin %q may only contain one wildcard", origin)
+			}
+			c.wildcards = append(c.wildcards, [2]string{scheme, host})
+		case strings.Contains(origin, "*"):
+			return nil, fmt.Errorf("cors: wildcard in origin %q must be a leading subdomain", origin)
+		default:
+			c.exact = append(c.exact, origin)
+		}
+	}
+	// Credentials for every origin would let any site act as the user
+	if c.anyOrigin && opts.AllowCredentials {
+		return nil, fmt.Errorf("cors: origin \"*\" can't be combined with AllowCredentials")
+	}
+
+	c.methods = opts.AllowedMethods
+	if len(c.methods) == 0 {
+		c.methods = []string{"GET", "HEAD", "POST"}
+	}
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers = append(c.headers, strings.ToLower(h))
+	}
+	c.exposed = strings.Join(opts.ExposedHeaders, ", ")
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return c, nil
+}
+
+// Middleware answers preflight requests itself and adds CORS headers to other responses
+func (c *CORS) Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw, ok := w.(server.ResponseWriter)
+		origin := req.Headers.Get("Origin")
+		if !ok || origin == "" {
+			return next(w, req)
+		}
+
+		h := rw.Header()
+		preflight := req.RequestLine.Method == "OPTIONS" && req.Headers.Get("Access-Control-Request-Method") != ""
+		if !preflight {
+			h.AddVary("Origin")
+			if c.allowOrigin(origin) {
+				c.setOrigin(rw, origin)
+				if c.exposed != "" {
+					h.Set("Access-Control-Expose-Headers", c.exposed)
+				}
+			}
+			return next(w, req)
+		}
+
+		// A preflight never reaches the handler, a refusal just lacks the allow headers
+		h.AddVary("Origin")
+		h.AddVary("Access-Control-Request-Method")
+		h.AddVary("Access-Control-Request-Headers")
+		rw.WriteHeader(int(response.StatusNoContent))
+
+		method := req.Headers.Get("Access-Control-Request-Method")
+		requested := splitList(req.Headers.Get("Access-Control-Request-Headers"))
+		if !c.allowOrigin(origin) || !slices.Contains(c.methods, method) || !c.allowHeaders(requested) {
+			return nil
+		}
+
+		c.setOrigin(rw, origin)
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+		if len(requested) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+		return nil
+	}
+}
+
+// setOrigin sets Allow-Origin, echoing the origin unless any origin is allowed
+func (c *CORS) setOrigin(rw server.ResponseWriter, origin string) {
+	h := rw.Header()
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin reports whether an Origin value matches the configured origins
+func (c *CORS) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if slices.Contains(c.exact, lower) {
+		return true
+	}
+	for _, w := range c.wildcards {
+		// The wildcard needs at least one label, "https://example.com" doesn't match "https://*.example.com"
+		if strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) && len(lower) > len(w[0])+len(w[1]) {
+			return true
+		}
+	}
+	for _, re := range c.opts.AllowedOriginPatterns {
+		if loc := re.FindStringIndex(origin); loc != nil && loc[0] == 0 && loc[1] == len(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports whether every requested header is allowed
+func (c *CORS) allowHeaders(requested []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, name := range requested {
+		if !slices.Contains(c.headers, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList splits a comma separated header list into lowercased names
+func splitList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names
+}
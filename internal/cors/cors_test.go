@@ -0,0 +1,120 @@
+package cors
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve runs the middleware over a handler that writes "handled" and returns the raw response
+func serve(t *testing.T, c *CORS, raw string) (string, bool) {
+	t.Helper()
+	handled := false
+	h := c.Middleware(func(w io.Writer, req *request.Request) *server.HandlerError {
+		handled = true
+		_, _ = w.Write([]byte("handled"))
+		return nil
+	})
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	w := response.NewWriter(&out)
+	require.Nil(t, h(w, req))
+	require.NoError(t, w.SendResponse())
+	return out.String(), handled
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{AllowedOrigins: []string{"https://*.*.example.com"}})
+	assert.Error(t, err)
+	_, err = New(Options{AllowedOrigins: []string{"https://app.*.com"}})
+	assert.Error(t, err)
+	_, err = New(Options{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	assert.Error(t, err)
+}
+
+func TestAllowOrigin(t *testing.T) {
+	c, err := New(Options{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`http://localhost:\d+`)},
+	})
+	require.NoError(t, err)
+
+	for origin, want := range map[string]bool{
+		"https://app.example.com":      true,
+		"HTTPS://APP.EXAMPLE.COM":      true,
+		"http://app.example.com":       false,
+		"https://a.b.example.org":      true,
+		"https://example.org":          false,
+		"https://evilexample.org":      false,
+		"http://localhost:5173":        true,
+		"http://localhost:5173.evil":   false,
+		"https://app.example.com.evil": false,
+	} {
+		assert.Equal(t, want, c.allowOrigin(origin), origin)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	c, err := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "X-Api-Key"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	// Test: Preflight is answered with 204 without reaching the handler
+	out, handled := serve(t, c, "OPTIONS /items HTTP/1.1\r\nHost: api\r\nOrigin: https://app.example.com\r\nAccess-Control-Request-Method: PUT\r\nAccess-Control-Request-Headers: content-type, x-api-key\r\n\r\n")
+	assert.False(t, handled)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 204 No Content\r\n"))
+	assert.Contains(t, out, "Access-Control-Allow-Origin: https://app.example.com\r\n")
+	assert.Contains(t, out, "Access-Control-Allow-Credentials: true\r\n")
+	assert.Contains(t, out, "Access-Control-Allow-Methods: GET, PUT\r\n")
+	assert.Contains(t, out, "Access-Control-Allow-Headers: content-type, x-api-key\r\n")
+	assert.Contains(t, out, "Access-Control-Max-Age: 600\r\n")
+	assert.Contains(t, out, "Vary: Origin, Access-Control-Request-Method, Access-Control-Request-Headers\r\n")
+	assert.NotContains(t, out, "handled")
+
+	// Test: Disallowed method, header or origin gets a bare 204
+	for _, raw := range []string{
+		"OPTIONS /items HTTP/1.1\r\nHost: api\r\nOrigin: https://app.example.com\r\nAccess-Control-Request-Method: DELETE\r\n\r\n",
+		"OPTIONS /items HTTP/1.1\r\nHost: api\r\nOrigin: https://app.example.com\r\nAccess-Control-Request-Method: PUT\r\nAccess-Control-Request-Headers: x-secret\r\n\r\n",
+		"OPTIONS /items HTTP/1.1\r\nHost: api\r\nOrigin: https://evil.example\r\nAccess-Control-Request-Method: GET\r\n\r\n",
+	} {
+		out, handled = serve(t, c, raw)
+		assert.False(t, handled)
+		assert.True(t, strings.HasPrefix(out, "HTTP/1.1 204 No Content\r\n"))
+		assert.NotContains(t, out, "Access-Control-Allow-Origin")
+	}
+
+	// Test: Actual requests are decorated and handled
+	out, handled = serve(t, c, "GET /items HTTP/1.1\r\nHost: api\r\nOrigin: https://app.example.com\r\n\r\n")
+	assert.True(t, handled)
+	assert.Contains(t, out, "Access-Control-Allow-Origin: https://app.example.com\r\n")
+	assert.Contains(t, out, "Access-Control-Expose-Headers: X-Request-Id\r\n")
+	assert.Contains(t, out, "Vary: Origin\r\n")
+
+	// Test: Other origins are handled without CORS headers, same-origin requests untouched
+	out, handled = serve(t, c, "GET /items HTTP/1.1\r\nHost: api\r\nOrigin: https://evil.example\r\n\r\n")
+	assert.True(t, handled)
+	assert.NotContains(t, out, "Access-Control-")
+	out, _ = serve(t, c, "GET /items HTTP/1.1\r\nHost: api\r\n\r\n")
+	assert.NotContains(t, out, "Vary")
+
+	// Test: Any origin without credentials uses the wildcard
+	c, err = New(Options{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+	out, _ = serve(t, c, "GET / HTTP/1.1\r\nHost: api\r\nOrigin: https://anywhere.example\r\n\r\n")
+	assert.Contains(t, out, "Access-Control-Allow-Origin: *\r\n")
+}
@@ -0,0 +1,187 @@
+package fileserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// copyBlockSize is how much content is buffered before it is flushed to the connection
+const copyBlockSize = 32 * 1024
+
+// ServeContent writes content as the response to req, answering Range requests with
+// 206 Partial Content (multipart/byteranges for several ranges) or 416 when none are
+// satisfiable. If-Range is honoured against the ETag set on w, or modTime.
+// The Content-Type is taken from w if set, then from name's extension, then sniffed.
+// The body is streamed in blocks, and HEAD never reads content.
+// Usable from any handler with an io.ReadSeeker
+func ServeContent(w io.Writer, req *request.Request, name string, modTime time.Time, content io.ReadSeeker) *server.HandlerError {
+	rw, ok := w.(server.ResponseWriter)
+	if !ok {
+		return &server.HandlerError{StatusCode: 500, Message: "fileserver: response writer does not support headers\n"}
+	}
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error seeking content: %v\n", err)}
+	}
+	ctype, herr := detectContentType(rw, req, name, content)
+	if herr != nil {
+		return herr
+	}
+
+	h := rw.Header()
+	h.Set("Accept-Ranges", "bytes")
+	if !modTime.IsZero() {
+		h.Set("Last-Modified", modTime.UTC().Format(headers.TimeFormat))
+	}
+
+	rangeHeader := req.Headers.Get("Range")
+	if req.RequestLine.Method != "GET" || !ifRangeMatches(req, h, modTime) {
+		rangeHeader = ""
+	}
+
+	ranges, err := request.ParseRange(rangeHeader, size)
+	if errors.Is(err, request.ErrNoOverlap) {
+		h.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return &server.HandlerError{StatusCode: 416, Message: "Range Not Satisfiable\n"}
+	}
+	// Malformed ranges are ignored and the full representation is sent
+	if err != nil || sumLength(ranges) > size {
+		ranges = nil
+	}
+
+	switch len(ranges) {
+	case 0:
+		h.Set("Content-Length", strconv.FormatInt(size, 10))
+		if req.RequestLine.Method == "HEAD" {
+			return nil
+		}
+		return copyRange(rw, content, request.ByteRange{Start: 0, Length: size})
+	case 1:
+		h.Set("Content-Range", ranges[0].ContentRange(size))
+		h.Set("Content-Length", strconv.FormatInt(ranges[0].Length, 10))
+		rw.WriteHeader(206)
+		return copyRange(rw, content, ranges[0])
+	}
+
+	boundary := newBoundary()
+	h.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	rw.WriteHeader(206)
+	for _, r := range ranges {
+		_, err = fmt.Fprintf(rw, "--%s\r\nContent-Type: %s\r\nContent-Range: %s\r\n\r\n", boundary, ctype, r.ContentRange(size))
+		if err != nil {
+			return writeError(err)
+		}
+		if herr := copyRange(rw, content, r); herr != nil {
+			return herr
+		}
+		if _, err = io.WriteString(rw, "\r\n"); err != nil {
+			return writeError(err)
+		}
+	}
+	if _, err = fmt.Fprintf(rw, "--%s--\r\n", boundary); err != nil {
+		return writeError(err)
+	}
+	return nil
+}
+
+// detectContentType sets and returns the Content-Type, sniffing the start of content if needed.
+// HEAD doesn't sniff and reports an unknown type as application/octet-stream instead
+func detectContentType(rw server.ResponseWriter, req *request.Request, name string, content io.ReadSeeker) (string, *server.HandlerError) {
+	if ctype := rw.Header().Get("Content-Type"); ctype != "" {
+		return ctype, nil
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" && req.RequestLine.Method == "HEAD" {
+		ctype = "application/octet-stream"
+	}
+	if ctype == "" {
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return "", &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error seeking content: %v\n", err)}
+		}
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(content, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error reading content: %v\n", err)}
+		}
+		ctype = sniff(buf[:n])
+	}
+
+	rw.Header().Set("Content-Type", ctype)
+	return ctype, nil
+}
+
+// ifRangeMatches reports whether a Range header should be honoured given If-Range
+// (RFC 9110 Section 13.1.5). ETags use strong comparison, dates must match exactly
+func ifRangeMatches(req *request.Request, h headers.Headers, modTime time.Time) bool {
+	ifRange := strings.TrimSpace(req.Headers.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := h.Get("ETag")
+		return etag != "" && !strings.HasPrefix(ifRange, "W/") && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+
+	date, err := time.Parse(headers.TimeFormat, ifRange)
+	if err != nil || modTime.IsZero() {
+		return false
+	}
+	return modTime.Truncate(time.Second).Equal(date)
+}
+
+// copyRange copies a single range of content to rw in blocks, flushing between them so
+// at most one block is held in memory. A range that fits in one block stays buffered
+func copyRange(rw server.ResponseWriter, content io.ReadSeeker, r request.ByteRange) *server.HandlerError {
+	if _, err := content.Seek(r.Start, io.SeekStart); err != nil {
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error seeking content: %v\n", err)}
+	}
+	for remaining := r.Length; remaining > 0; {
+		n := min(remaining, copyBlockSize)
+		if _, err := io.CopyN(rw, content, n); err != nil {
+			return writeError(err)
+		}
+		remaining -= n
+		if remaining == 0 {
+			break
+		}
+		if err := rw.Flush(); err != nil {
+			return writeError(err)
+		}
+	}
+	return nil
+}
+
+// sumLength totals the bytes covered by ranges
+func sumLength(ranges []request.ByteRange) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.Length
+	}
+	return total
+}
+
+// newBoundary returns a random multipart boundary
+func newBoundary() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeError wraps a failure to write the body
+func writeError(err error) *server.HandlerError {
+	return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error writing response: %v\n", err)}
+}
@@ -0,0 +1,104 @@
+package fileserver
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// serveContent runs ServeContent for a raw request and returns the raw response
+func serveContent(t *testing.T, raw, etag string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	herr := ServeContent(w, req, "alphabet.txt", modTime, strings.NewReader("abcdefghijklmnopqrstuvwxyz"))
+	if herr != nil {
+		w.WriteHeader(herr.StatusCode)
+		w.DiscardBody()
+		_, _ = w.Write([]byte(herr.Message))
+	}
+	require.NoError(t, w.SendResponse())
+	return buf.String()
+}
+
+func TestServeContentRanges(t *testing.T) {
+	// Test: No Range header sends everything and advertises range support
+	out := serveContent(t, "GET / HTTP/1.1\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "Accept-Ranges: bytes\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nabcdefghijklmnopqrstuvwxyz"))
+
+	// Test: Single range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-4\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+	assert.Contains(t, out, "Content-Range: bytes 0-4/26\r\n")
+	assert.Contains(t, out, "Content-Length: 5\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nabcde"))
+
+	// Test: Suffix range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=-3\r\n\r\n", "")
+	assert.Contains(t, out, "Content-Range: bytes 23-25/26\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nxyz"))
+
+	// Test: Multiple ranges produce multipart/byteranges
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-1, 24-\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+	boundary := regexp.MustCompile(`Content-Type: multipart/byteranges; boundary=(\w+)\r\n`).FindStringSubmatch(out)
+	require.Len(t, boundary, 2)
+	body := "--" + boundary[1] + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Range: bytes 0-1/26\r\n\r\n" +
+		"ab\r\n" +
+		"--" + boundary[1] + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Range: bytes 24-25/26\r\n\r\n" +
+		"yz\r\n" +
+		"--" + boundary[1] + "--\r\n"
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\n"+body))
+
+	// Test: Unsatisfiable range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=100-\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 416 Range Not Satisfiable\r\n"))
+	assert.Contains(t, out, "Content-Range: bytes */26\r\n")
+
+	// Test: Malformed range is ignored
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=z-\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+
+	// Test: Range is ignored for other methods
+	out = serveContent(t, "POST / HTTP/1.1\r\nRange: bytes=0-1\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+}
+
+func TestServeContentIfRange(t *testing.T) {
+	// Test: Matching strong ETag honours the range
+	out := serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: \"v1\"\r\n\r\n", `"v1"`)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+
+	// Test: Changed ETag sends the full representation
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: \"v0\"\r\n\r\n", `"v1"`)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+
+	// Test: Weak ETags never match
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: W/\"v1\"\r\n\r\n", `W/"v1"`)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+
+	// Test: Matching Last-Modified date honours the range
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: Thu, 07 Mar 2024 13:55:36 GMT\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+
+	// Test: Older date sends the full representation
+	out = serveContent(t, "GET / HTTP/1.1\r\nRange: bytes=0-0\r\nIf-Range: Wed, 06 Mar 2024 13:55:36 GMT\r\n\r\n", "")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+}
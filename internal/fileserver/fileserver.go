@@ -0,0 +1,177 @@
+package fileserver
+
+import (
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// indexPage is served in place of a directory listing when present
+const indexPage = "index.html"
+
+// FileServer serves files from an fs.FS, mapping the request path onto the FS root
+type FileServer struct {
+	FS fs.FS
+	// ShowListing generates an HTML listing for directories without an index.html
+	ShowListing bool
+	// AllowDotfiles serves files and directories whose names start with "."
+	AllowDotfiles bool
+}
+
+// New creates a FileServer rooted at fsys, with listings and dotfiles disabled
+func New(fsys fs.FS) *FileServer {
+	return &FileServer{FS: fsys}
+}
+
+// Handler serves the file or directory named by the request target
+func (f *FileServer) Handler(w io.Writer, req *request.Request) *server.HandlerError {
+	rw, ok := w.(server.ResponseWriter)
+	if !ok {
+		return &server.HandlerError{StatusCode: 500, Message: "fileserver: response writer does not support headers\n"}
+	}
+
+	method := req.RequestLine.Method
+	if method != "GET" && method != "HEAD" {
+		rw.Header().Set("Allow", "GET, HEAD")
+		return &server.HandlerError{StatusCode: 405, Message: "Method Not Allowed\n"}
+	}
+
+	target, err := url.ParseRequestURI(req.RequestLine.RequestTarget)
+	if err != nil || !strings.HasPrefix(target.Path, "/") {
+		return &server.HandlerError{StatusCode: 400, Message: "Invalid request target\n"}
+	}
+
+	name, ok := f.resolve(target.Path)
+	if !ok {
+		return notFound()
+	}
+
+	info, err := fs.Stat(f.FS, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return notFound()
+		}
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error reading file: %v\n", err)}
+	}
+
+	if info.IsDir() {
+		// Relative links in a directory page only resolve correctly with a trailing slash.
+		// The Location is relative, so a target like "//evil.example" can't become a
+		// protocol-relative redirect to another host
+		if !strings.HasSuffix(target.Path, "/") {
+			location := (&url.URL{Path: path.Base(target.Path) + "/"}).String()
+			if target.RawQuery != "" {
+				location += "?" + target.RawQuery
+			}
+			rw.Header().Set("Location", location)
+			rw.WriteHeader(301)
+			return nil
+		}
+
+		index := path.Join(name, indexPage)
+		if info, err := fs.Stat(f.FS, index); err == nil && !info.IsDir() {
+			return f.serveFile(rw, req, index, info)
+		}
+		if f.ShowListing {
+			return f.serveListing(rw, name, target.Path)
+		}
+		return notFound()
+	}
+
+	return f.serveFile(rw, req, name, info)
+}
+
+// resolve converts a cleaned URL path into an fs.FS name, rejecting dotfiles unless allowed
+func (f *FileServer) resolve(urlPath string) (string, bool) {
+	cleaned := path.Clean(urlPath)
+	name := strings.TrimPrefix(cleaned, "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+
+	if !f.AllowDotfiles && name != "." {
+		for _, segment := range strings.Split(name, "/") {
+			if strings.HasPrefix(segment, ".") {
+				return "", false
+			}
+		}
+	}
+	return name, true
+}
+
+// serveFile writes a regular file, supporting range requests
+func (f *FileServer) serveFile(rw server.ResponseWriter, req *request.Request, name string, info fs.FileInfo) *server.HandlerError {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return notFound()
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		content = &fileSeeker{fsys: f.FS, name: name, file: file, size: info.Size()}
+	}
+
+	return ServeContent(rw, req, name, info.ModTime(), content)
+}
+
+// fileSeeker adapts an fs.File that can't seek, using the size from Stat. Seeking only
+// moves the offset, reads then skip forward or reopen the file to go back
+type fileSeeker struct {
+	fsys   fs.FS
+	name   string
+	file   fs.File
+	size   int64
+	pos    int64 // Position of file
+	offset int64 // Position reads should start from
+}
+
+func (s *fileSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("fileserver: negative seek offset")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *fileSeeker) Read(p []byte) (int, error) {
+	if s.offset < s.pos {
+		file, err := s.fsys.Open(s.name)
+		if err != nil {
+			return 0, err
+		}
+		_ = s.file.Close()
+		s.file, s.pos = file, 0
+	}
+	if s.offset > s.pos {
+		skipped, err := io.CopyN(io.Discard, s.file, s.offset-s.pos)
+		s.pos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.file.Read(p)
+	s.pos += int64(n)
+	s.offset = s.pos
+	return n, err
+}
+
+// notFound is the error returned for anything that can't or mustn't be served
+func notFound() *server.HandlerError {
+	return &server.HandlerError{StatusCode: 404, Message: "Not Found\n"}
+}
@@ -0,0 +1,191 @@
+package fileserver
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var modTime = time.Date(2024, time.March, 7, 13, 55, 36, 0, time.UTC)
+
+var testFS = fstest.MapFS{
+	"index.html":          {Data: []byte("<h1>home</h1>"), ModTime: modTime},
+	"style.css":           {Data: []byte("body {}"), ModTime: modTime},
+	"notes":               {Data: []byte("just some text"), ModTime: modTime},
+	"logo":                {Data: []byte("\x89PNG\r\n\x1a\nrest"), ModTime: modTime},
+	".env":                {Data: []byte("SECRET=1"), ModTime: modTime},
+	"docs/guide.txt":      {Data: []byte("guide"), ModTime: modTime},
+	"docs/a b.txt":        {Data: []byte("spaces"), ModTime: modTime},
+	"docs/.hidden/x.txt":  {Data: []byte("hidden"), ModTime: modTime},
+	"assets/index.html":   {Data: []byte("<p>assets</p>"), ModTime: modTime},
+	"empty/placeholder.x": {Data: []byte(""), ModTime: modTime},
+}
+
+// serve runs the handler for a request line and returns the raw response
+func serve(t *testing.T, f *FileServer, method, target string) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	herr := f.Handler(w, req)
+	if herr != nil {
+		w.WriteHeader(herr.StatusCode)
+		w.DiscardBody()
+		_, _ = w.Write([]byte(herr.Message))
+	}
+	require.NoError(t, w.SendResponse())
+	return buf.String()
+}
+
+func TestFileServer(t *testing.T) {
+	f := New(testFS)
+
+	// Test: File with a known extension
+	out := serve(t, f, "GET", "/style.css")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "Content-Type: text/css; charset=utf-8\r\n")
+	assert.Contains(t, out, "Content-Length: 7\r\n")
+	assert.Contains(t, out, "Last-Modified: Thu, 07 Mar 2024 13:55:36 GMT\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\nbody {}"))
+
+	// Test: Content type is sniffed without an extension
+	assert.Contains(t, serve(t, f, "GET", "/notes"), "Content-Type: text/plain; charset=utf-8\r\n")
+	assert.Contains(t, serve(t, f, "GET", "/logo"), "Content-Type: image/png\r\n")
+
+	// Test: Root serves index.html
+	out = serve(t, f, "GET", "/")
+	assert.Contains(t, out, "Content-Type: text/html; charset=utf-8\r\n")
+	assert.True(t, strings.HasSuffix(out, "<h1>home</h1>"))
+
+	// Test: Percent-encoded names
+	assert.True(t, strings.HasSuffix(serve(t, f, "GET", "/docs/a%20b.txt"), "spaces"))
+
+	// Test: Directory without trailing slash redirects, keeping the query
+	out = serve(t, f, "GET", "/assets?v=1")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 301 Moved Permanently\r\n"))
+	assert.Contains(t, out, "Location: assets/?v=1\r\n")
+
+	// Test: A target starting with "//" is not redirected to another host
+	out = serve(t, f, "GET", "//docs")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 301 Moved Permanently\r\n"))
+	assert.Contains(t, out, "Location: docs/\r\n")
+	assert.True(t, strings.HasSuffix(serve(t, f, "GET", "/assets/"), "<p>assets</p>"))
+
+	// Test: Directory without index.html is not found when listings are disabled
+	assert.True(t, strings.HasPrefix(serve(t, f, "GET", "/docs/"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Traversal outside the root never escapes
+	assert.True(t, strings.HasSuffix(serve(t, f, "GET", "/../../style.css"), "body {}"))
+	assert.True(t, strings.HasPrefix(serve(t, f, "GET", "/docs/..%2f..%2f..%2fetc/passwd"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Dotfiles are hidden by default
+	assert.True(t, strings.HasPrefix(serve(t, f, "GET", "/.env"), "HTTP/1.1 404 Not Found\r\n"))
+	assert.True(t, strings.HasPrefix(serve(t, f, "GET", "/docs/.hidden/x.txt"), "HTTP/1.1 404 Not Found\r\n"))
+
+	// Test: Dotfiles can be enabled
+	f.AllowDotfiles = true
+	assert.True(t, strings.HasSuffix(serve(t, f, "GET", "/.env"), "SECRET=1"))
+	f.AllowDotfiles = false
+
+	// Test: Unsupported method
+	out = serve(t, f, "POST", "/style.css")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 405 Method Not Allowed\r\n"))
+	assert.Contains(t, out, "Allow: GET, HEAD\r\n")
+
+	// Test: Missing file
+	assert.True(t, strings.HasPrefix(serve(t, f, "GET", "/missing.txt"), "HTTP/1.1 404 Not Found\r\n"))
+}
+
+func TestListing(t *testing.T) {
+	f := New(testFS)
+	f.ShowListing = true
+
+	// Test: Generated listing links to visible entries only
+	out := serve(t, f, "GET", "/docs/")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "Content-Type: text/html; charset=utf-8\r\n")
+	assert.Contains(t, out, "<title>Index of /docs/</title>")
+	assert.Contains(t, out, `<a href="../">../</a>`)
+	assert.Contains(t, out, `<a href="a%20b.txt">a b.txt</a>`)
+	assert.Contains(t, out, `<a href="guide.txt">guide.txt</a>`)
+	assert.NotContains(t, out, ".hidden")
+
+	// Test: index.html still takes precedence
+	assert.True(t, strings.HasSuffix(serve(t, f, "GET", "/assets/"), "<p>assets</p>"))
+}
+
+// streamFS hides Seek from the files of fsys and counts the bytes read from them
+type streamFS struct {
+	fsys fstest.MapFS
+	read *int
+}
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return streamFile{File: file, read: s.read}, nil
+}
+
+type streamFile struct {
+	fs.File
+	read *int
+}
+
+func (f streamFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	*f.read += n
+	return n, err
+}
+
+func TestStreaming(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	read := 0
+	f := New(streamFS{fsys: fstest.MapFS{"large.txt": {Data: []byte(large), ModTime: modTime}}, read: &read})
+
+	// Test: A large file is streamed with its Content-Length, without chunked encoding
+	out := serve(t, f, "GET", "/large.txt")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "Content-Length: 100000\r\n")
+	assert.NotContains(t, out, "Transfer-Encoding")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\n"+large))
+
+	// Test: Ranges work on files that can't seek, including going backwards
+	out = serveRaw(t, f, "GET /large.txt HTTP/1.1\r\nRange: bytes=99990-, 5-9\r\n\r\n", false)
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 206 Partial Content\r\n"))
+	assert.Contains(t, out, "Content-Range: bytes 99990-99999/100000\r\n\r\n0123456789\r\n")
+	assert.Contains(t, out, "Content-Range: bytes 5-9/100000\r\n\r\n56789\r\n")
+
+	// Test: HEAD declares the length without reading the file
+	read = 0
+	out = serveRaw(t, f, "HEAD /large.txt HTTP/1.1\r\n\r\n", true)
+	assert.Contains(t, out, "Content-Length: 100000\r\n")
+	assert.True(t, strings.HasSuffix(out, "\r\n\r\n"))
+	assert.Zero(t, read)
+}
+
+// serveRaw runs the handler for a raw request, leaving out the body as the server does for HEAD
+func serveRaw(t *testing.T, f *FileServer, raw string, omitBody bool) string {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+	if omitBody {
+		w.OmitBody()
+	}
+	require.Nil(t, f.Handler(w, req))
+	require.NoError(t, w.SendResponse())
+	return buf.String()
+}
@@ -0,0 +1,51 @@
+package fileserver
+
+import (
+	"fmt"
+	"html"
+	"httpfromtcp/internal/server"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// serveListing writes an HTML page linking to every visible entry in the directory
+func (f *FileServer) serveListing(rw server.ResponseWriter, name, urlPath string) *server.HandlerError {
+	entries, err := fs.ReadDir(f.FS, name)
+	if err != nil {
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error reading directory: %v\n", err)}
+	}
+
+	var b strings.Builder
+	title := html.EscapeString("Index of " + urlPath)
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>" + title + "</title></head>\n")
+	b.WriteString("<body>\n<h1>" + title + "</h1>\n<ul>\n")
+	if urlPath != "/" {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+
+	// fs.ReadDir returns entries sorted by name
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if !f.AllowDotfiles && strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := (&url.URL{Path: entryName}).EscapedPath()
+		// A name containing a colon would otherwise be read as a URL scheme
+		if strings.Contains(strings.SplitN(href, "/", 2)[0], ":") {
+			href = "./" + href
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(entryName))
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = rw.Write([]byte(b.String()))
+	if err != nil {
+		return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error writing response: %v\n", err)}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package fileserver
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of the content is inspected when sniffing
+const sniffLen = 512
+
+// signature maps a magic number prefix to the media type it identifies
+type signature struct {
+	prefix      []byte
+	contentType string
+}
+
+// signatures covers the common binary formats served as static assets
+var signatures = []signature{
+	{[]byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{[]byte("\xff\xd8\xff"), "image/jpeg"},
+	{[]byte("GIF87a"), "image/gif"},
+	{[]byte("GIF89a"), "image/gif"},
+	{[]byte("%PDF-"), "application/pdf"},
+	{[]byte("PK\x03\x04"), "application/zip"},
+	{[]byte("\x1f\x8b\x08"), "application/x-gzip"},
+	{[]byte("wOFF"), "font/woff"},
+	{[]byte("wOF2"), "font/woff2"},
+}
+
+// htmlPrefixes are lowercase openings that mark a document as HTML
+var htmlPrefixes = []string{"<!doctype html", "<html", "<head", "<body"}
+
+// sniff guesses a media type from the first bytes of content, a small subset of
+// the WHATWG MIME Sniffing algorithm
+func sniff(content []byte) string {
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+
+	for _, sig := range signatures {
+		if bytes.HasPrefix(content, sig.prefix) {
+			return sig.contentType
+		}
+	}
+
+	trimmed := bytes.ToLower(bytes.TrimLeft(content, " \t\r\n"))
+	for _, prefix := range htmlPrefixes {
+		if bytes.HasPrefix(trimmed, []byte(prefix)) {
+			return "text/html; charset=utf-8"
+		}
+	}
+
+	// A truncated multi-byte rune at the cut-off point is still text
+	if utf8.Valid(content) || (len(content) == sniffLen && utf8.Valid(content[:len(content)-utf8.UTFMax])) {
+		if bytes.IndexByte(content, 0) == -1 {
+			return "text/plain; charset=utf-8"
+		}
+	}
+	return "application/octet-stream"
+}
@@ -1,14 +1,24 @@
 package headers
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 )
 
+// TimeFormat is the IMF-fixdate layout used for dates in headers (RFC 9110 Section 5.6.7)
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
 // Headers defines the headers map type with a key-value pair of strings
 type Headers map[string]string
 
+// Errors returned by Parse, wrapped with the offending header line
+var (
+	ErrInvalidFormat = errors.New("invalid header format")
+	ErrInvalidKey    = errors.New("invalid characters in header key")
+)
+
 // Valid character specification from RFC 9110
 var tcharRegex = regexp.MustCompile(`^[!#$%&'*+\-.^_` + "`" + `|~0-9a-zA-Z]+$`)
 
@@ -49,7 +59,7 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		colon := strings.IndexByte(requestLine, ':')
 		if colon == -1 {
 			// No colon in the line == invalid header format
-			return 0, false, fmt.Errorf("invalid header format: %s - must include colon", requestLine)
+			return 0, false, fmt.Errorf("%w: %s - must include colon", ErrInvalidFormat, requestLine)
 		}
 
 		// Extract the key and value
@@ -58,7 +68,7 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 
 		// Check for whitespace between colon and key
 		if strings.TrimSpace(key) != key {
-			return 0, false, fmt.Errorf("invalid header format: %s - spaces between colon and key", requestLine)
+			return 0, false, fmt.Errorf("%w: %s - spaces between colon and key", ErrInvalidFormat, requestLine)
 		}
 
 		// Check if key has invalid characters using validateKey
@@ -75,8 +85,8 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 
 		// Check if header already exists in map
 		if existingValue, exists := h[key]; exists {
-			// If key exists, append new value with comma separator
-			h[key] = existingValue + ", " + value
+			// If key exists, append new value with the list separator
+			h[key] = existingValue + separator(key) + value
 		} else {
 			// If key doesn't exist, simply set the value
 			h[key] = value
@@ -88,6 +98,27 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 	}
 }
 
+// separator returns how repeated field lines of key are combined. Set-Cookie can't be
+// joined with commas (RFC 9110 Section 5.3), so its values are kept on separate lines
+func separator(key string) string {
+	if strings.EqualFold(key, "Set-Cookie") {
+		return "\n"
+	}
+	return ", "
+}
+
+// Values returns the values of key, one per field line for Set-Cookie
+func (h Headers) Values(key string) []string {
+	value := h.Get(key)
+	if value == "" {
+		return nil
+	}
+	if separator(key) == "\n" {
+		return strings.Split(value, "\n")
+	}
+	return []string{value}
+}
+
 // NewHeaders Creates a new Headers map
 func NewHeaders() Headers {
 	return make(map[string]string)
@@ -96,7 +127,7 @@ func NewHeaders() Headers {
 // validateKey checks if the key uses only valid tchar characters
 func validateKey(key string) error {
 	if !tcharRegex.MatchString(key) {
-		return fmt.Errorf("invalid characters in header key %s", key)
+		return fmt.Errorf("%w %s", ErrInvalidKey, key)
 	}
 	return nil
 }
@@ -111,3 +142,38 @@ func (h Headers) Get(key string) string {
 	}
 	return ""
 }
+
+// Set replaces any existing value for key, case-insensitive, keeping key's casing
+func (h Headers) Set(key, value string) {
+	h.Del(key)
+	h[key] = value
+}
+
+// Del removes key, case-insensitive
+func (h Headers) Del(key string) {
+	for k := range h {
+		if strings.EqualFold(k, key) {
+			delete(h, k)
+		}
+	}
+}
+
+// Add appends value to any existing value for key, separated by a comma.
+// Set-Cookie values are kept apart and written as separate field lines
+func (h Headers) Add(key, value string) {
+	if existing := h.Get(key); existing != "" {
+		value = existing + separator(key) + value
+	}
+	h.Set(key, value)
+}
+
+// AddVary adds a field name to Vary unless it is already listed
+func (h Headers) AddVary(field string) {
+	for _, v := range strings.Split(h.Get("Vary"), ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.EqualFold(v, field) {
+			return
+		}
+	}
+	h.Add("Vary", field)
+}
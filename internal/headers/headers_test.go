@@ -77,3 +77,73 @@ func TestHeaders_Parse(t *testing.T) {
 	assert.Equal(t, len(data), n)
 	assert.True(t, done)
 }
+
+func TestParseQualityValues(t *testing.T) {
+	// Test: Weights order the list, ties keep their listed order
+	values := ParseQualityValues("deflate;q=0.5, gzip, br;q=1.0, identity;q=0")
+	assert.Equal(t, []QualityValue{
+		{Value: "gzip", Q: 1},
+		{Value: "br", Q: 1},
+		{Value: "deflate", Q: 0.5},
+		{Value: "identity", Q: 0},
+	}, values)
+
+	// Test: Parameters other than q are kept
+	values = ParseQualityValues("text/html;level=1;q=0.7, */*;q=0.1")
+	assert.Equal(t, []QualityValue{
+		{Value: "text/html;level=1", Q: 0.7},
+		{Value: "*/*", Q: 0.1},
+	}, values)
+
+	// Test: Invalid weights count as not acceptable
+	values = ParseQualityValues("gzip;q=2")
+	assert.Equal(t, []QualityValue{{Value: "gzip", Q: 0}}, values)
+
+	// Test: Empty header
+	assert.Empty(t, ParseQualityValues(""))
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	offers := []string{"application/json", "text/html"}
+
+	// Test: Highest weight wins
+	assert.Equal(t, "text/html", NegotiateMediaType("application/json;q=0.5, text/html", offers))
+
+	// Test: The most specific range decides, even when a wildcard weighs more
+	assert.Equal(t, "text/html", NegotiateMediaType("*/*;q=0.9, application/json;q=0", offers))
+	assert.Equal(t, "application/json", NegotiateMediaType("application/*, text/*;q=0.2", offers))
+
+	// Test: Ties and empty Accept go to the first offer
+	assert.Equal(t, "application/json", NegotiateMediaType("*/*", offers))
+	assert.Equal(t, "application/json", NegotiateMediaType("", offers))
+
+	// Test: Nothing acceptable
+	assert.Equal(t, "", NegotiateMediaType("image/png", offers))
+}
+
+func TestHeaders_SetAddDel(t *testing.T) {
+	// Test: Set replaces values regardless of key casing
+	headers := NewHeaders()
+	headers["content-type"] = "text/plain"
+	headers.Set("Content-Type", "application/json")
+	assert.Equal(t, Headers{"Content-Type": "application/json"}, headers)
+
+	// Test: Add appends with a comma
+	headers.Add("Vary", "Origin")
+	headers.Add("vary", "Accept-Encoding")
+	assert.Equal(t, "Origin, Accept-Encoding", headers.Get("Vary"))
+
+	// Test: Del is case-insensitive
+	headers.Del("CONTENT-TYPE")
+	assert.Equal(t, "", headers.Get("Content-Type"))
+
+	// Test: Set-Cookie values stay separate instead of being comma joined
+	headers = NewHeaders()
+	_, _, err := headers.Parse([]byte("Set-Cookie: a=1; Expires=Thu, 01 Jan 2026 00:00:00 GMT\r\n"))
+	require.NoError(t, err)
+	_, _, err = headers.Parse([]byte("Set-Cookie: b=2\r\n"))
+	require.NoError(t, err)
+	headers.Add("Set-Cookie", "c=3")
+	assert.Equal(t, []string{"a=1; Expires=Thu, 01 Jan 2026 00:00:00 GMT", "b=2", "c=3"}, headers.Values("set-cookie"))
+	assert.Nil(t, headers.Values("Cookie"))
+}
@@ -0,0 +1,100 @@
+package headers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// QualityValue is an element of a weighted list such as Accept or Accept-Encoding
+type QualityValue struct {
+	Value string  // The element with any parameters other than q, e.g. "text/html;level=1"
+	Q     float64 // Weight between 0 and 1, 0 means "not acceptable"
+}
+
+// ParseQualityValues parses a comma separated list with optional q weights
+// (RFC 9110 Section 12.4.2), returning elements ordered by descending weight.
+// Elements of equal weight keep the order they were listed in, invalid weights count as 0
+func ParseQualityValues(value string) []QualityValue {
+	var values []QualityValue
+	for _, element := range strings.Split(value, ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+
+		qv := QualityValue{Q: 1}
+		var params []string
+		for i, part := range strings.Split(element, ";") {
+			part = strings.TrimSpace(part)
+			if i == 0 {
+				params = append(params, part)
+				continue
+			}
+			if name, weight, ok := strings.Cut(part, "="); ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+				q, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				qv.Q = q
+				continue
+			}
+			params = append(params, part)
+		}
+		qv.Value = strings.Join(params, ";")
+		values = append(values, qv)
+	}
+
+	slices.SortStableFunc(values, func(a, b QualityValue) int {
+		switch {
+		case a.Q > b.Q:
+			return -1
+		case a.Q < b.Q:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return values
+}
+
+// NegotiateMediaType picks the offered media type the Accept value weights highest,
+// using the most specific matching range (RFC 9110 Section 12.5.1). Ties go to the
+// earlier offer, an empty Accept accepts the first offer, and "" means none is acceptable
+func NegotiateMediaType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := ParseQualityValues(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		offerType, offerSub, _ := strings.Cut(strings.ToLower(offer), "/")
+
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			mediaRange, _, _ := strings.Cut(r.Value, ";")
+			rangeType, rangeSub, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+
+			s := -1
+			switch {
+			case rangeType == offerType && rangeSub == offerSub:
+				s = 2
+			case rangeType == offerType && rangeSub == "*":
+				s = 1
+			case rangeType == "*" && rangeSub == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,117 @@
+// Package jsonhttp decodes JSON request bodies and writes JSON responses for server handlers
+package jsonhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"strings"
+)
+
+// ContentType is the media type written by Write
+const ContentType = "application/json"
+
+// DefaultMaxBytes caps request bodies when DecodeOptions.MaxBytes is zero
+const DefaultMaxBytes = 1 << 20
+
+// DecodeOptions controls how strictly Decode treats a request body
+type DecodeOptions struct {
+	// MaxBytes rejects larger bodies with 413, zero uses DefaultMaxBytes
+	MaxBytes int64
+	// AllowUnknownFields accepts object members that don't map to a struct field
+	AllowUnknownFields bool
+}
+
+// Decode reads a single JSON value from the request body into v. It returns 415 for
+// a non-JSON Content-Type, 413 for an oversized body and 400 for malformed JSON
+func Decode(req *request.Request, v any, opts DecodeOptions) *server.HandlerError {
+	if !isJSON(req.MediaType()) {
+		return &server.HandlerError{
+			StatusCode: int(response.StatusUnsupportedMediaType),
+			Message:    fmt.Sprintf("Content-Type must be %s\n", ContentType),
+		}
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if int64(len(req.Body)) > maxBytes {
+		return &server.HandlerError{
+			StatusCode: int(response.StatusContentTooLarge),
+			Message:    fmt.Sprintf("request body must not be larger than %d bytes\n", maxBytes),
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(req.Body))
+	if !opts.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return badRequest(err)
+	}
+	// Anything but whitespace after the value is an error
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return &server.HandlerError{StatusCode: int(response.StatusBadRequest), Message: "request body must contain a single JSON value\n"}
+	}
+	return nil
+}
+
+// badRequest turns a decoding error into a 400 with a message fit for the client
+func badRequest(err error) *server.HandlerError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	msg := err.Error()
+	switch {
+	case errors.Is(err, io.EOF):
+		msg = "request body must not be empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		msg = "request body contains truncated JSON"
+	case errors.As(err, &syntaxErr):
+		msg = fmt.Sprintf("request body contains malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		msg = fmt.Sprintf("request body has the wrong type for field %q", typeErr.Field)
+	case strings.HasPrefix(msg, "json: unknown field "):
+		msg = "request body contains unknown field " + strings.TrimPrefix(msg, "json: unknown field ")
+	}
+	return &server.HandlerError{StatusCode: int(response.StatusBadRequest), Message: msg + "\n"}
+}
+
+// isJSON reports whether a media type is application/json or a +json structured syntax
+func isJSON(mediaType string) bool {
+	return mediaType == ContentType || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// Write marshals v and writes it with the given status and a JSON Content-Type
+func Write(w io.Writer, statusCode int, v any) *server.HandlerError {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return &server.HandlerError{StatusCode: int(response.StatusInternalError), Message: "error encoding response\n"}
+	}
+
+	if rw, ok := w.(server.ResponseWriter); ok {
+		rw.Header().Set("Content-Type", ContentType)
+		rw.WriteHeader(statusCode)
+	}
+	if _, err := w.Write(body); err != nil {
+		return &server.HandlerError{StatusCode: int(response.StatusInternalError), Message: fmt.Sprintf("%v\n", err)}
+	}
+	return nil
+}
+
+// Respond writes v like Write, or returns 406 when the request's Accept rules out JSON
+func Respond(w io.Writer, req *request.Request, statusCode int, v any) *server.HandlerError {
+	if headers.NegotiateMediaType(req.Headers.Get("Accept"), []string{ContentType}) == "" {
+		return &server.HandlerError{
+			StatusCode: int(response.StatusNotAcceptable),
+			Message:    fmt.Sprintf("response is only available as %s\n", ContentType),
+		}
+	}
+	return Write(w, statusCode, v)
+}
@@ -0,0 +1,117 @@
+package jsonhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+// roundTrip serves a JSON echo handler and returns the raw response to a request with body
+func roundTrip(t *testing.T, srv *server.Server, extraHeaders, body string) (string, string) {
+	t.Helper()
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer func() { _ = srv.Close() }()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "POST /greet HTTP/1.1\r\nHost: localhost\r\n%sContent-Length: %d\r\n\r\n%s", extraHeaders, len(body), body)
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	head, respBody, _ := strings.Cut(string(out), "\r\n\r\n")
+	return head, respBody
+}
+
+func echo(opts DecodeOptions) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		var g greeting
+		if herr := Decode(req, &g, opts); herr != nil {
+			return herr
+		}
+		return Respond(w, req, 201, map[string]string{"hello": g.Name})
+	}
+}
+
+func TestDecodeAndWrite(t *testing.T) {
+	// Test: JSON in, JSON out with status
+	head, body := roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: application/json; charset=utf-8\r\n", `{"name":"gopher"}`)
+	assert.Contains(t, head, "HTTP/1.1 201 Created")
+	assert.Contains(t, strings.ToLower(head), "content-type: application/json")
+	assert.JSONEq(t, `{"hello":"gopher"}`, body)
+
+	// Test: Wrong Content-Type is 415
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: text/plain\r\n", `{"name":"gopher"}`)
+	assert.Contains(t, head, "HTTP/1.1 415 ")
+
+	// Test: Unknown fields are rejected unless allowed
+	head, body = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: application/json\r\n", `{"name":"gopher","age":3}`)
+	assert.Contains(t, head, "HTTP/1.1 400 ")
+	assert.Contains(t, body, `unknown field "age"`)
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{AllowUnknownFields: true})}, "Content-Type: application/json\r\n", `{"name":"gopher","age":3}`)
+	assert.Contains(t, head, "HTTP/1.1 201 ")
+
+	// Test: Size cap is 413
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{MaxBytes: 8})}, "Content-Type: application/json\r\n", `{"name":"gopher"}`)
+	assert.Contains(t, head, "HTTP/1.1 413 ")
+
+	// Test: Trailing data and empty bodies are 400
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: application/json\r\n", `{"name":"a"}{"name":"b"}`)
+	assert.Contains(t, head, "HTTP/1.1 400 ")
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: application/json\r\n", ``)
+	assert.Contains(t, head, "HTTP/1.1 400 ")
+
+	// Test: Accept that rules out JSON is 406
+	head, _ = roundTrip(t, &server.Server{Handler: echo(DecodeOptions{})}, "Content-Type: application/json\r\nAccept: text/html\r\n", `{"name":"gopher"}`)
+	assert.Contains(t, head, "HTTP/1.1 406 ")
+}
+
+func TestProblemDetails(t *testing.T) {
+	// Test: Errors render as problem details when the server asks for it
+	head, body := roundTrip(t, &server.Server{Handler: echo(DecodeOptions{}), ProblemDetails: true}, "Content-Type: text/plain\r\n", `hi`)
+	assert.Contains(t, head, "HTTP/1.1 415 ")
+	assert.Contains(t, strings.ToLower(head), "content-type: application/problem+json")
+	var problem map[string]any
+	require.NoError(t, json.Unmarshal([]byte(body), &problem))
+	assert.Equal(t, map[string]any{
+		"title":  "Unsupported Media Type",
+		"status": float64(415),
+		"detail": "Content-Type must be application/json",
+	}, problem)
+
+	// Test: An error's own Problem is used even without the server option
+	handler := func(w io.Writer, req *request.Request) *server.HandlerError {
+		return &server.HandlerError{
+			StatusCode: 403,
+			Message:    "insufficient credit",
+			Problem: &server.Problem{
+				Type:       "https://example.com/probs/out-of-credit",
+				Instance:   "/account/12345",
+				Extensions: map[string]any{"balance": 30},
+			},
+		}
+	}
+	head, body = roundTrip(t, &server.Server{Handler: handler}, "", "")
+	assert.Contains(t, head, "HTTP/1.1 403 ")
+	assert.JSONEq(t, `{
+		"type": "https://example.com/probs/out-of-credit",
+		"title": "Forbidden",
+		"status": 403,
+		"detail": "insufficient credit",
+		"instance": "/account/12345",
+		"balance": 30
+	}`, body)
+}
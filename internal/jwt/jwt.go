@@ -0,0 +1,262 @@
+// Package jwt verifies JSON Web Tokens (RFC 7519) in JWS compact form signed with
+// HS256, RS256 or ES256, and provides middleware exposing their claims to handlers
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/auth"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verifier.Verify, wrapped with details
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported algorithm")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("token expired")
+	ErrNotYetValid      = errors.New("token not yet valid")
+	ErrInvalidClaims    = errors.New("invalid claims")
+)
+
+// Claims are the registered claims of a token, with every claim also available in Raw
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	Raw       map[string]any
+}
+
+// header is the JOSE header of a JWS
+type header struct {
+	Alg  string `json:"alg"`
+	Kid  string `json:"kid"`
+	Typ  string `json:"typ"`
+	Crit []any  `json:"crit"`
+}
+
+// Verifier checks token signatures against Keys and validates the registered claims
+type Verifier struct {
+	Keys *KeySet
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking exp and nbf
+	Leeway time.Duration
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+}
+
+// Verify parses a compact JWS, checks its signature and claims, and returns the claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 parts", ErrMalformed)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	if len(h.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical header", ErrMalformed)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature encoding", ErrMalformed)
+	}
+
+	keys := v.Keys.lookup(h.Kid, h.Alg)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no %q key with kid %q", ErrUnsupportedAlg, h.Alg, h.Kid)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	if !slices.ContainsFunc(keys, func(k Key) bool { return verifySignature(k, signingInput, signature) }) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Only look at the payload once the signature is known to be good
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload encoding", ErrMalformed)
+	}
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil || raw == nil {
+		return nil, fmt.Errorf("%w: payload must be a JSON object", ErrMalformed)
+	}
+
+	claims, err := registeredClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment into v
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks a signature with one key
+func verifySignature(k Key, signingInput, signature []byte) bool {
+	digest := sha256.Sum256(signingInput)
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.Material.([]byte))
+		mac.Write(signingInput)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		return rsa.VerifyPKCS1v15(k.Material.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		// JWS uses the fixed width R || S encoding rather than ASN.1 (RFC 7518 Section 3.4)
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k.Material.(*ecdsa.PublicKey), digest[:], r, s)
+	}
+	return false
+}
+
+// registeredClaims extracts and type checks the registered claims (RFC 7519 Section 4.1)
+func registeredClaims(raw map[string]any) (*Claims, error) {
+	c := &Claims{Raw: raw}
+	var err error
+	str := func(name string) string {
+		v, ok := raw[name]
+		if !ok {
+			return ""
+		}
+		s, isString := v.(string)
+		if !isString {
+			err = fmt.Errorf("%w: %s must be a string", ErrInvalidClaims, name)
+		}
+		return s
+	}
+	date := func(name string) time.Time {
+		v, ok := raw[name]
+		if !ok {
+			return time.Time{}
+		}
+		n, isNumber := v.(json.Number)
+		f, convErr := n.Float64()
+		if !isNumber || convErr != nil || math.IsInf(f, 0) {
+			err = fmt.Errorf("%w: %s must be a NumericDate", ErrInvalidClaims, name)
+			return time.Time{}
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	c.Issuer, c.Subject, c.ID = str("iss"), str("sub"), str("jti")
+	c.ExpiresAt, c.NotBefore, c.IssuedAt = date("exp"), date("nbf"), date("iat")
+
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: aud must contain strings", ErrInvalidClaims)
+			}
+			c.Audience = append(c.Audience, s)
+		}
+	default:
+		return nil, fmt.Errorf("%w: aud must be a string or array", ErrInvalidClaims)
+	}
+	return c, err
+}
+
+// validate checks time, issuer and audience claims
+func (v *Verifier) validate(c *Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(v.Leeway)) {
+		return fmt.Errorf("%w at %s", ErrExpired, c.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if !c.NotBefore.IsZero() && now.Add(v.Leeway).Before(c.NotBefore) {
+		return fmt.Errorf("%w until %s", ErrNotYetValid, c.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidClaims, c.Issuer)
+	}
+	if v.Audience != "" && !slices.Contains(c.Audience, v.Audience) {
+		return fmt.Errorf("%w: token is not intended for %q", ErrInvalidClaims, v.Audience)
+	}
+	return nil
+}
+
+// VerifyToken implements auth.TokenVerifier, using the sub claim as the subject
+func (v *Verifier) VerifyToken(_ context.Context, token string) (auth.Principal, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{Scheme: "Bearer", Subject: claims.Subject}, nil
+}
+
+type contextKey struct{}
+
+// ClaimsFromRequest returns the claims attached by Middleware
+func ClaimsFromRequest(req *request.Request) (*Claims, bool) {
+	c, ok := req.Context().Value(contextKey{}).(*Claims)
+	return c, ok
+}
+
+// Middleware requires a valid bearer JWT, attaching its claims and an auth.Principal to the request
+func (v *Verifier) Middleware(realm string) server.Middleware {
+	return func(next server.Handler) server.Handler {
+		return func(w io.Writer, req *request.Request) *server.HandlerError {
+			token, ok := auth.BearerToken(req)
+			if !ok {
+				return auth.Unauthorized(w, auth.Challenge{Scheme: "Bearer", Realm: realm}, "Unauthorized")
+			}
+
+			claims, err := v.Verify(token)
+			if err != nil {
+				return auth.Unauthorized(w, auth.Challenge{
+					Scheme: "Bearer",
+					Realm:  realm,
+					Params: map[string]string{"error": "invalid_token", "error_description": err.Error()},
+				}, "Unauthorized")
+			}
+
+			req = req.WithContext(context.WithValue(req.Context(), contextKey{}, claims))
+			return next(w, auth.WithPrincipal(req, auth.Principal{Scheme: "Bearer", Subject: claims.Subject}))
+		}
+	}
+}
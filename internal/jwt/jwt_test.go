@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/auth"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	hmacSecret = bytes.Repeat([]byte("k"), 32)
+	rsaKey, _  = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _   = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now        = time.Unix(1_800_000_000, 0)
+)
+
+// sign builds a compact JWS for the given header and claims
+func sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	h, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	p, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, hmacSecret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case RS256:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// writeJWKS writes a key set with one key of each type and returns its path
+func writeJWKS(t *testing.T) string {
+	t.Helper()
+	doc := fmt.Sprintf(`{"keys":[
+		{"kty":"oct","kid":"hs","k":%q},
+		{"kty":"RSA","kid":"rs","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"es","crv":"P-256","x":%q,"y":%q},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AA"}
+	]}`,
+		b64(hmacSecret),
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))),
+	)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	keys, err := LoadJWKS(writeJWKS(t))
+	require.NoError(t, err)
+	require.Len(t, keys.keys, 3)
+
+	v := &Verifier{Keys: keys, Issuer: "https://id.example", Audience: "orders", Leeway: 30 * time.Second, Now: func() time.Time { return now }}
+	claims := map[string]any{
+		"iss":  "https://id.example",
+		"sub":  "user-1",
+		"aud":  []string{"billing", "orders"},
+		"exp":  now.Add(time.Minute).Unix(),
+		"nbf":  now.Add(-time.Minute).Unix(),
+		"iat":  now.Add(-time.Minute).Unix(),
+		"role": "admin",
+	}
+
+	// Test: Each algorithm verifies with its JWKS key
+	for alg, kid := range map[string]string{HS256: "hs", RS256: "rs", ES256: "es"} {
+		c, err := v.Verify(sign(t, alg, kid, claims))
+		require.NoError(t, err, alg)
+		assert.Equal(t, "user-1", c.Subject)
+		assert.Equal(t, []string{"billing", "orders"}, c.Audience)
+		assert.Equal(t, now.Add(time.Minute), c.ExpiresAt)
+		assert.Equal(t, "admin", c.Raw["role"])
+	}
+
+	// Test: A token without kid is tried against keys of its algorithm
+	_, err = v.Verify(sign(t, RS256, "", claims))
+	assert.NoError(t, err)
+
+	// Test: Tampered payloads and mismatched algorithms are rejected
+	token := sign(t, HS256, "hs", claims)
+	parts := strings.Split(token, ".")
+	forged := b64([]byte(`{"sub":"admin"}`))
+	_, err = v.Verify(parts[0] + "." + forged + "." + parts[2])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = v.Verify(sign(t, HS256, "rs", claims))
+	assert.ErrorIs(t, err, ErrUnsupportedAlg)
+	_, err = v.Verify(sign(t, "none", "", claims))
+	assert.ErrorIs(t, err, ErrUnsupportedAlg)
+	_, err = v.Verify("not.a-token")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	// Test: Time claims honour the leeway
+	v.Now = func() time.Time { return now.Add(time.Minute + 10*time.Second) }
+	_, err = v.Verify(token)
+	assert.NoError(t, err)
+	v.Now = func() time.Time { return now.Add(2 * time.Minute) }
+	_, err = v.Verify(token)
+	assert.ErrorIs(t, err, ErrExpired)
+	v.Now = func() time.Time { return now.Add(-2 * time.Minute) }
+	_, err = v.Verify(token)
+	assert.ErrorIs(t, err, ErrNotYetValid)
+	v.Now = func() time.Time { return now }
+
+	// Test: Issuer and audience must match
+	v.Audience = "inventory"
+	_, err = v.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+	v.Audience, v.Issuer = "orders", "https://other.example"
+	_, err = v.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+
+	// Test: Claims with the wrong types are rejected
+	v.Issuer = ""
+	_, err = v.Verify(sign(t, HS256, "hs", map[string]any{"aud": "orders", "exp": "tomorrow"}))
+	assert.ErrorIs(t, err, ErrInvalidClaims)
+}
+
+func TestKeySet(t *testing.T) {
+	// Test: Key material must suit the algorithm
+	_, err := NewKeySet(Key{ID: "a", Algorithm: HS256, Material: []byte("short")})
+	assert.Error(t, err)
+	_, err = NewKeySet(Key{ID: "a", Algorithm: RS256, Material: hmacSecret})
+	assert.Error(t, err)
+	_, err = NewKeySet(Key{ID: "a", Algorithm: "PS256", Material: &rsaKey.PublicKey})
+	assert.Error(t, err)
+
+	// Test: EC points off the curve are rejected
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	keys, err := NewKeySet(Key{ID: "es", Algorithm: ES256, Material: &ecKey.PublicKey})
+	require.NoError(t, err)
+	v := &Verifier{Keys: keys, Now: func() time.Time { return now }}
+
+	var subject, role string
+	h := v.Middleware("api")(func(w io.Writer, req *request.Request) *server.HandlerError {
+		claims, _ := ClaimsFromRequest(req)
+		p, _ := auth.FromRequest(req)
+		subject, role = p.Subject, claims.Raw["role"].(string)
+		return nil
+	})
+	call := func(authorization string) (*response.Writer, *server.HandlerError) {
+		req, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\nAuthorization: " + authorization + "\r\n\r\n"))
+		require.NoError(t, err)
+		w := response.NewWriter(&bytes.Buffer{})
+		return w, h(w, req)
+	}
+
+	// Test: Claims reach the handler
+	_, herr := call("Bearer " + sign(t, ES256, "es", map[string]any{"sub": "user-1", "role": "admin", "exp": now.Add(time.Hour).Unix()}))
+	require.Nil(t, herr)
+	assert.Equal(t, "user-1", subject)
+	assert.Equal(t, "admin", role)
+
+	// Test: Expired tokens get an invalid_token challenge
+	w, herr := call("Bearer " + sign(t, ES256, "es", map[string]any{"sub": "user-1", "exp": now.Add(-time.Hour).Unix()}))
+	require.NotNil(t, herr)
+	assert.Equal(t, 401, herr.StatusCode)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Supported signing algorithms (RFC 7518 Section 3.1)
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Key is a verification key. Material is a []byte secret for HS256, an *rsa.PublicKey
+// for RS256 or a P-256 *ecdsa.PublicKey for ES256
+type Key struct {
+	ID        string
+	Algorithm string
+	Material  any
+}
+
+// KeySet holds the keys tokens may be signed with, looked up by their kid header
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet creates an in-memory KeySet, checking each key matches its algorithm
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	for _, k := range keys {
+		if err := k.check(); err != nil {
+			return nil, err
+		}
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+// check reports whether the key material suits the algorithm, which prevents a
+// token from choosing, say, HS256 with an RSA public key as the secret
+func (k Key) check() error {
+	var ok bool
+	switch k.Algorithm {
+	case HS256:
+		var secret []byte
+		secret, ok = k.Material.([]byte)
+		ok = ok && len(secret) >= 32
+	case RS256:
+		var pub *rsa.PublicKey
+		pub, ok = k.Material.(*rsa.PublicKey)
+		ok = ok && pub.N.BitLen() >= 2048
+	case ES256:
+		var pub *ecdsa.PublicKey
+		pub, ok = k.Material.(*ecdsa.PublicKey)
+		ok = ok && pub.Curve == elliptic.P256()
+	default:
+		return fmt.Errorf("jwt: key %q: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+	if !ok {
+		return fmt.Errorf("jwt: key %q: unsuitable key material for %s", k.ID, k.Algorithm)
+	}
+	return nil
+}
+
+// lookup returns the keys that may verify a token with the given kid and alg.
+// A token without kid is tried against every key of its algorithm
+func (s *KeySet) lookup(kid, alg string) []Key {
+	var keys []Key
+	for _, k := range s.keys {
+		if k.Algorithm == alg && (kid == "" || k.ID == kid) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// jwk is the subset of a JSON Web Key (RFC 7517) needed for verification
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS reads a KeySet from a local JWKS file
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// ParseJWKS parses a JWKS document of oct, RSA and P-256 EC keys. Keys for other uses
+// or algorithms are skipped
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwt: parsing JWKS: %w", err)
+	}
+
+	var keys []Key
+	for i, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.key()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt: JWKS key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(keys...)
+}
+
+var errUnsupportedKey = errors.New("unsupported key")
+
+// key converts a JWK into a Key, inferring the algorithm from the key type
+func (j jwk) key() (Key, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch {
+	case j.Kty == "oct" && (j.Alg == "" || j.Alg == HS256):
+		secret, err := decode(j.K)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{ID: j.Kid, Algorithm: HS256, Material: secret}, nil
+
+	case j.Kty == "RSA" && (j.Alg == "" || j.Alg == RS256):
+		n, err := decode(j.N)
+		if err != nil {
+			return Key{}, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return Key{}, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return Key{}, fmt.Errorf("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		return Key{ID: j.Kid, Algorithm: RS256, Material: pub}, nil
+
+	case j.Kty == "EC" && j.Crv == "P-256" && (j.Alg == "" || j.Alg == ES256):
+		x, err := decode(j.X)
+		if err != nil {
+			return Key{}, err
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return Key{}, err
+		}
+		// crypto/ecdh rejects points that are not on the curve
+		x, y = leftPad(x, 32), leftPad(y, 32)
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return Key{}, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return Key{ID: j.Kid, Algorithm: ES256, Material: pub}, nil
+	}
+	return Key{}, errUnsupportedKey
+}
+
+// leftPad pads b with leading zeros to size bytes
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// contentType is the media type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes label values per the Prometheus text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var _ server.Observer = (*Registry)(nil)
+
+// Handler serves the registry in Prometheus text exposition format
+func (r *Registry) Handler(w io.Writer, _ *request.Request) *server.HandlerError {
+	if rw, ok := w.(server.ResponseWriter); ok {
+		rw.Header().Set("Content-Type", contentType)
+	}
+	if err := r.WritePrometheus(w); err != nil {
+		return &server.HandlerError{
+			StatusCode: 500,
+			Message:    fmt.Sprintf("Error writing metrics: %v", err),
+		}
+	}
+	return nil
+}
+
+// WritePrometheus writes every metric in Prometheus text exposition format (version 0.0.4)
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	buf := &bytes.Buffer{}
+
+	r.mu.Lock()
+	r.writeRequests(buf)
+	r.writeDurations(buf)
+	writeHeader(buf, "http_requests_in_flight", "gauge", "Number of requests currently being served.")
+	fmt.Fprintf(buf, "http_requests_in_flight %d\n", r.inFlight)
+	writeHeader(buf, "http_open_connections", "gauge", "Number of currently open connections.")
+	fmt.Fprintf(buf, "http_open_connections %d\n", r.openConns)
+	writeHeader(buf, "http_read_bytes_total", "counter", "Total bytes read from connections.")
+	fmt.Fprintf(buf, "http_read_bytes_total %d\n", r.bytesRead)
+	writeHeader(buf, "http_written_bytes_total", "counter", "Total bytes written to connections.")
+	fmt.Fprintf(buf, "http_written_bytes_total %d\n", r.bytesWritten)
+	r.writeParseErrors(buf)
+	r.mu.Unlock()
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeRequests writes the requests counter, sorted by labels for stable output
+func (r *Registry) writeRequests(buf *bytes.Buffer) {
+	writeHeader(buf, "http_requests_total", "counter", "Total number of HTTP requests by method, route and status.")
+
+	keys := make([]requestKey, 0, len(r.requests))
+	for k := range r.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		return cmp.Or(
+			cmp.Compare(a.method, b.method),
+			cmp.Compare(a.route, b.route),
+			cmp.Compare(a.status, b.status),
+		)
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(buf, "http_requests_total{method=\"%s\",route=\"%s\",status=\"%d\"} %d\n",
+			escape(k.method), escape(k.route), k.status, r.requests[k])
+	}
+}
+
+// writeDurations writes the latency histograms
+func (r *Registry) writeDurations(buf *bytes.Buffer) {
+	name := "http_request_duration_seconds"
+	writeHeader(buf, name, "histogram", "Latency of HTTP requests in seconds.")
+
+	keys := make([]routeKey, 0, len(r.durations))
+	for k := range r.durations {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b routeKey) int {
+		return cmp.Or(cmp.Compare(a.method, b.method), cmp.Compare(a.route, b.route))
+	})
+
+	for _, k := range keys {
+		h := r.durations[k]
+		labels := fmt.Sprintf("method=\"%s\",route=\"%s\"", escape(k.method), escape(k.route))
+		for i, upper := range r.Buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(upper), h.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+// writeParseErrors writes the parse error counter by kind
+func (r *Registry) writeParseErrors(buf *bytes.Buffer) {
+	writeHeader(buf, "http_parse_errors_total", "counter", "Total number of requests that failed to parse by error kind.")
+
+	kinds := make([]string, 0, len(r.parseErrors))
+	for k := range r.parseErrors {
+		kinds = append(kinds, k)
+	}
+	slices.Sort(kinds)
+
+	for _, k := range kinds {
+		fmt.Fprintf(buf, "http_parse_errors_total{kind=\"%s\"} %s\n", escape(k), strconv.FormatUint(r.parseErrors[k], 10))
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines for a metric family
+func writeHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, kind)
+}
+
+// escape escapes a label value
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnmatchedRoute is the route label of requests no route was found for
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod is the method label of requests with a nonstandard method
+const OtherMethod = "OTHER"
+
+// methods are the method label values, anything else is counted as OtherMethod
+var methods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// DefaultBuckets are the latency histogram upper bounds in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry collects server metrics and implements server.Observer
+type Registry struct {
+	// Route maps a request to the route label, e.g. MuxRoute. It must return a small,
+	// fixed set of values, never the raw path, as each one creates new series that
+	// clients could otherwise grow without limit. Without it every request is UnmatchedRoute
+	Route func(req *request.Request) string
+	// Buckets are the histogram upper bounds in ascending order, and must not change once requests are observed
+	Buckets []float64
+
+	mu           sync.Mutex
+	requests     map[requestKey]uint64
+	durations    map[routeKey]*histogram
+	parseErrors  map[string]uint64
+	inFlight     int64
+	openConns    int64
+	bytesRead    uint64
+	bytesWritten uint64
+}
+
+// requestKey identifies a requests counter series
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// routeKey identifies a latency histogram series
+type routeKey struct {
+	method string
+	route  string
+}
+
+// histogram holds cumulative bucket counts alongside the sum and count of observations
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewRegistry creates an empty Registry using DefaultBuckets
+func NewRegistry() *Registry {
+	return &Registry{
+		Buckets:     DefaultBuckets,
+		requests:    make(map[requestKey]uint64),
+		durations:   make(map[routeKey]*histogram),
+		parseErrors: make(map[string]uint64),
+	}
+}
+
+// ConnOpened increments the open connections gauge
+func (r *Registry) ConnOpened() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.openConns++
+}
+
+// ConnClosed decrements the open connections gauge and adds the bytes transferred
+func (r *Registry) ConnClosed(bytesRead, bytesWritten int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.openConns--
+	r.bytesRead += uint64(bytesRead)
+	r.bytesWritten += uint64(bytesWritten)
+}
+
+// RequestStarted increments the in-flight requests gauge
+func (r *Registry) RequestStarted(_ *request.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight++
+}
+
+// RequestFinished records a completed request's status and latency
+func (r *Registry) RequestFinished(req *request.Request, status int, _ int, duration time.Duration) {
+	method := req.RequestLine.Method
+	if !methods[method] {
+		method = OtherMethod
+	}
+	route := r.route(req)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight--
+	r.requests[requestKey{method: method, route: route, status: status}]++
+
+	key := routeKey{method: method, route: route}
+	h, ok := r.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.Buckets))}
+		r.durations[key] = h
+	}
+	h.observe(r.Buckets, duration.Seconds())
+}
+
+// ParseError counts a request that failed to parse, by kind
+func (r *Registry) ParseError(kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parseErrors[kind]++
+}
+
+// route returns the route label for a request
+func (r *Registry) route(req *request.Request) string {
+	if r.Route == nil {
+		return UnmatchedRoute
+	}
+	if route := r.Route(req); route != "" {
+		return route
+	}
+	return UnmatchedRoute
+}
+
+// MuxRoute labels requests with the Mux path they are routed to, bounded by the routes registered
+func MuxRoute(m *server.Mux) func(req *request.Request) string {
+	return func(req *request.Request) string {
+		path, _, _ := strings.Cut(req.RequestLine.RequestTarget, "?")
+		return m.Pattern(path)
+	}
+}
+
+// observe records a single value into every bucket it fits in
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, upper := range buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// formatFloat formats a float the way the Prometheus text format expects
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
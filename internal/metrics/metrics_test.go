@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRequest(method, target string) *request.Request {
+	return &request.Request{
+		RequestLine: request.RequestLine{Method: method, RequestTarget: target, HttpVersion: "1.1"},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	mux := server.NewMux()
+	mux.Handle("GET", "/coffee", nil)
+	reg := NewRegistry()
+	reg.Buckets = []float64{0.1, 1}
+	reg.Route = MuxRoute(mux)
+
+	// Test: Requests, connections and parse errors are all recorded
+	reg.ConnOpened()
+	reg.ConnOpened()
+	reg.RequestStarted(newRequest("GET", "/coffee?size=large"))
+	reg.RequestFinished(newRequest("GET", "/coffee?size=large"), 200, 15, 50*time.Millisecond)
+	reg.RequestStarted(newRequest("GET", "/coffee"))
+	reg.RequestFinished(newRequest("GET", "/coffee"), 200, 15, 500*time.Millisecond)
+	reg.RequestStarted(newRequest("POST", "/tea"))
+	reg.ConnClosed(100, 200)
+	reg.ParseError("header")
+	reg.ParseError("header")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, reg.WritePrometheus(buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE http_requests_total counter\n")
+	assert.Contains(t, out, "http_requests_total{method=\"GET\",route=\"/coffee\",status=\"200\"} 2\n")
+	assert.Contains(t, out, "# TYPE http_request_duration_seconds histogram\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{method=\"GET\",route=\"/coffee\",le=\"0.1\"} 1\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{method=\"GET\",route=\"/coffee\",le=\"1\"} 2\n")
+	assert.Contains(t, out, "http_request_duration_seconds_bucket{method=\"GET\",route=\"/coffee\",le=\"+Inf\"} 2\n")
+	assert.Contains(t, out, "http_request_duration_seconds_sum{method=\"GET\",route=\"/coffee\"} 0.55\n")
+	assert.Contains(t, out, "http_request_duration_seconds_count{method=\"GET\",route=\"/coffee\"} 2\n")
+	assert.Contains(t, out, "http_requests_in_flight 1\n")
+	assert.Contains(t, out, "http_open_connections 1\n")
+	assert.Contains(t, out, "http_read_bytes_total 100\n")
+	assert.Contains(t, out, "http_written_bytes_total 200\n")
+	assert.Contains(t, out, "http_parse_errors_total{kind=\"header\"} 2\n")
+
+	// Test: Unrouted paths and nonstandard methods share bounded labels
+	reg.RequestFinished(newRequest("GET", "/random-1"), 404, 0, time.Millisecond)
+	reg.RequestFinished(newRequest("GET", "/random-2"), 404, 0, time.Millisecond)
+	reg.RequestFinished(newRequest("BREW", "/coffee"), 405, 0, time.Millisecond)
+	buf.Reset()
+	require.NoError(t, reg.WritePrometheus(buf))
+	out = buf.String()
+	assert.Contains(t, out, "http_requests_total{method=\"GET\",route=\"unmatched\",status=\"404\"} 2\n")
+	assert.Contains(t, out, "http_requests_total{method=\"OTHER\",route=\"/coffee\",status=\"405\"} 1\n")
+	assert.NotContains(t, out, "random")
+
+	// Test: Label values are escaped
+	reg.Route = func(req *request.Request) string { return "say \"hi\"" }
+	reg.RequestFinished(newRequest("GET", "/"), 404, 0, time.Millisecond)
+	buf.Reset()
+	require.NoError(t, reg.WritePrometheus(buf))
+	assert.Contains(t, buf.String(), `route="say \"hi\"",status="404"} 1`)
+}
+
+func TestServerIntegration(t *testing.T) {
+	reg := NewRegistry()
+	srv := &server.Server{
+		Handler:  reg.Handler,
+		Observer: reg,
+	}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// Test: A malformed request is counted as a parse error
+	send(t, addr, "GET / HTTP/1.1\r\nHost localhost\r\n\r\n")
+
+	// Test: Metrics are served in the exposition format
+	out := send(t, addr, "GET /metrics HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, out, "Content-Type: text/plain; version=0.0.4; charset=utf-8\r\n")
+	assert.Contains(t, out, "http_parse_errors_total{kind=\"header\"} 1\n")
+	assert.Contains(t, out, "http_requests_in_flight 1\n")
+
+	out = send(t, addr, "GET /metrics HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, out, "http_requests_total{method=\"GET\",route=\"unmatched\",status=\"200\"} 1\n")
+}
+
+// send writes a raw request to addr and returns the raw response
+func send(t *testing.T, addr, raw string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprint(conn, raw)
+	require.NoError(t, err)
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(out)
+}
@@ -0,0 +1,258 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"httpfromtcp/internal/auth"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ForwardProxy is a proxy for clients: it forwards absolute-form requests such as
+// "GET http://example.com/ HTTP/1.1" and tunnels "CONNECT example.com:443" requests
+type ForwardProxy struct {
+	// Allow and Deny hold destination rules: "example.com", "*.example.com",
+	// "example.com:443" or a CIDR such as "10.0.0.0/8". Deny wins, and when Allow is
+	// not empty a destination must match it. CIDR rules in Deny are also checked
+	// against the address a host name resolves to
+	Allow []string
+	Deny  []string
+
+	// Credentials, when set, requires Basic Proxy-Authorization checked against it
+	Credentials auth.BasicVerifier
+	Realm       string
+
+	DialTimeout time.Duration
+	Logger      *slog.Logger
+}
+
+// Handler proxies one client request
+func (p *ForwardProxy) Handler(w io.Writer, req *request.Request) *server.HandlerError {
+	if p.Credentials != nil {
+		if herr := p.authenticate(w, req); herr != nil {
+			return herr
+		}
+	}
+
+	if req.RequestLine.Method == "CONNECT" {
+		return p.tunnel(w, req)
+	}
+	return p.forward(w, req)
+}
+
+// authenticate checks Proxy-Authorization, answering 407 with a Proxy-Authenticate challenge
+func (p *ForwardProxy) authenticate(w io.Writer, req *request.Request) *server.HandlerError {
+	user, password, ok := auth.ParseBasic(req.Headers.Get("Proxy-Authorization"))
+	if ok && p.Credentials.VerifyBasic(user, password) {
+		return nil
+	}
+
+	if rw, ok := w.(server.ResponseWriter); ok {
+		challenge := auth.Challenge{Scheme: "Basic", Realm: p.Realm, Params: map[string]string{"charset": "UTF-8"}}
+		rw.Header().Set("Proxy-Authenticate", challenge.String())
+	}
+	return &server.HandlerError{StatusCode: int(response.StatusProxyAuthRequired), Message: "Proxy Authentication Required\n"}
+}
+
+// forward relays an absolute-form request to its origin server
+func (p *ForwardProxy) forward(w io.Writer, req *request.Request) *server.HandlerError {
+	target, err := url.Parse(req.RequestLine.RequestTarget)
+	if err != nil || target.Scheme != "http" || target.Host == "" {
+		return &server.HandlerError{StatusCode: int(response.StatusBadRequest), Message: "Proxy requests must use an absolute http:// target or CONNECT\n"}
+	}
+	host := target.Host
+	if target.Port() == "" {
+		host = net.JoinHostPort(target.Hostname(), "80")
+	}
+	if !p.allowed(host) {
+		return forbidden(host)
+	}
+
+	ctx := req.Context()
+	conn, err := p.dial(ctx, host)
+	if err != nil {
+		p.logger().Error("Error dialing origin", "host", host, "error", err)
+		return upstreamError(ctx, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	h := cloneEndToEnd(req.Headers)
+	h.Set("Host", target.Host)
+	h.Add("Via", "1.1 httpfromtcp")
+	if err := writeRequest(conn, req.RequestLine.Method, target.RequestURI(), h, req.Body); err != nil {
+		return upstreamError(ctx, err)
+	}
+
+	br := bufio.NewReader(conn)
+	status, respHeaders, err := readFinalHead(br)
+	if err != nil {
+		p.logger().Error("Error reading origin response", "host", host, "error", err)
+		return upstreamError(ctx, err)
+	}
+	respHeaders.Add("Via", "1.1 httpfromtcp")
+	if err := copyResponse(w, br, req.RequestLine.Method, status, respHeaders); err != nil {
+		return upstreamError(ctx, err)
+	}
+	return nil
+}
+
+// tunnel handles CONNECT by splicing the client connection to the destination
+func (p *ForwardProxy) tunnel(w io.Writer, req *request.Request) *server.HandlerError {
+	host := req.RequestLine.RequestTarget
+	if _, port, err := net.SplitHostPort(host); err != nil || port == "" {
+		return &server.HandlerError{StatusCode: int(response.StatusBadRequest), Message: "CONNECT target must be host:port\n"}
+	}
+	if !p.allowed(host) {
+		return forbidden(host)
+	}
+	hj, ok := w.(server.Hijacker)
+	if !ok {
+		return &server.HandlerError{StatusCode: int(response.StatusInternalError), Message: "Tunneling is not supported\n"}
+	}
+
+	ctx := req.Context()
+	upstream, err := p.dial(ctx, host)
+	if err != nil {
+		p.logger().Error("Error dialing tunnel destination", "host", host, "error", err)
+		return upstreamError(ctx, err)
+	}
+
+	client, buffered, err := hj.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return &server.HandlerError{StatusCode: int(response.StatusInternalError), Message: err.Error()}
+	}
+	defer client.Close()
+	defer upstream.Close()
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return nil
+	}
+	// Bytes the client sent right after CONNECT, e.g. a TLS ClientHello, go first
+	if len(buffered) > 0 {
+		if _, err := upstream.Write(buffered); err != nil {
+			return nil
+		}
+	}
+
+	// Close both ends if the server shuts down while the tunnel is open. The server
+	// releases hijacked connections from WriteTimeout, so long-lived tunnels stay up
+	stop := context.AfterFunc(ctx, func() {
+		_ = client.Close()
+		_ = upstream.Close()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go splice(&wg, upstream, client)
+	go splice(&wg, client, upstream)
+	wg.Wait()
+	return nil
+}
+
+// splice copies src to dst, then half-closes dst so the other direction can finish
+func splice(wg *sync.WaitGroup, dst, src net.Conn) {
+	defer wg.Done()
+	_, _ = io.Copy(dst, src)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// dial connects to host:port, refusing resolved addresses in a denied CIDR
+func (p *ForwardProxy) dial(ctx context.Context, hostport string) (net.Conn, error) {
+	timeout := p.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	d := net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			if ip, err := netip.ParseAddr(host); err == nil && matchesCIDR(p.Deny, ip) {
+				return fmt.Errorf("proxy: destination %s is denied", address)
+			}
+			return nil
+		},
+	}
+	return d.DialContext(ctx, "tcp", hostport)
+}
+
+// allowed applies the Allow and Deny rules to a host:port destination
+func (p *ForwardProxy) allowed(hostport string) bool {
+	for _, rule := range p.Deny {
+		if matchRule(rule, hostport) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, rule := range p.Allow {
+		if matchRule(rule, hostport) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule reports whether a destination rule covers host:port
+func matchRule(rule, hostport string) bool {
+	host, port, _ := net.SplitHostPort(hostport)
+	host = strings.ToLower(host)
+	rule = strings.ToLower(rule)
+
+	if prefix, err := netip.ParsePrefix(rule); err == nil {
+		ip, err := netip.ParseAddr(host)
+		return err == nil && prefix.Contains(ip.Unmap())
+	}
+	if ruleHost, rulePort, err := net.SplitHostPort(rule); err == nil {
+		if rulePort != port {
+			return false
+		}
+		rule = ruleHost
+	}
+	if suffix, ok := strings.CutPrefix(rule, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == rule
+}
+
+// matchesCIDR reports whether ip falls in any CIDR rule
+func matchesCIDR(rules []string, ip netip.Addr) bool {
+	for _, rule := range rules {
+		if prefix, err := netip.ParsePrefix(rule); err == nil && prefix.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbidden is the error for destinations the rules refuse
+func forbidden(host string) *server.HandlerError {
+	return &server.HandlerError{StatusCode: int(response.StatusForbidden), Message: fmt.Sprintf("Destination %s is not allowed\n", host)}
+}
+
+// logger returns the configured Logger or the slog default
+func (p *ForwardProxy) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/auth"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startEcho runs a raw TCP server that echoes each connection back in upper case
+func startEcho(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					line, err := br.ReadString('\n')
+					if err != nil {
+						return
+					}
+					_, _ = io.WriteString(conn, strings.ToUpper(line))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestForwardProxy(t *testing.T) {
+	origin := startServer(t, echo("origin"))
+	p := &ForwardProxy{}
+	addr := startServer(t, p.Handler)
+
+	// Test: Absolute-form requests are sent to the origin in origin-form
+	status, h, body := do(t, addr, "GET http://"+origin+"/path?q=1 HTTP/1.1\r\nHost: "+origin+"\r\nProxy-Connection: keep-alive\r\n\r\n")
+	assert.Equal(t, response.StatusCode(201), status)
+	assert.Equal(t, "origin", h.Get("X-Upstream"))
+	assert.Equal(t, "1.1 httpfromtcp", h.Get("Via"))
+	assert.Contains(t, body, "Host="+origin+"\n")
+	assert.Contains(t, body, "GET /path?q=1 ")
+
+	// Test: Origin-form and non-http targets are rejected
+	status, _, _ = do(t, addr, "GET /path HTTP/1.1\r\nHost: proxy\r\n\r\n")
+	assert.Equal(t, response.StatusBadRequest, status)
+	status, _, _ = do(t, addr, "GET https://"+origin+"/ HTTP/1.1\r\nHost: proxy\r\n\r\n")
+	assert.Equal(t, response.StatusBadRequest, status)
+}
+
+func TestConnectTunnel(t *testing.T) {
+	target := startEcho(t)
+	p := &ForwardProxy{}
+	addr := startServer(t, p.Handler)
+
+	// Test: CONNECT answers 200 and then splices bytes both ways, including any sent early
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\nearly bytes\n", target, target)
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	sl, _, err := response.ReadHead(br)
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusOK, sl.StatusCode)
+	assert.Equal(t, "Connection Established", sl.ReasonPhrase)
+
+	line, err := br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "EARLY BYTES\n", line)
+	_, err = io.WriteString(conn, "ping\n")
+	require.NoError(t, err)
+	line, err = br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "PING\n", line)
+
+	// Test: Bad CONNECT targets
+	status, _, _ := do(t, addr, "CONNECT example.com HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	assert.Equal(t, response.StatusBadRequest, status)
+
+	// Test: Tunnels outlive the server's WriteTimeout
+	srv := &server.Server{Handler: p.Handler, WriteTimeout: 50 * time.Millisecond}
+	_, err = srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	conn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", srv.Listener.Addr().(*net.TCPAddr).Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+	require.NoError(t, err)
+	br = bufio.NewReader(conn)
+	_, _, err = response.ReadHead(br)
+	require.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	_, err = io.WriteString(conn, "still there\n")
+	require.NoError(t, err)
+	line, err = br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "STILL THERE\n", line)
+}
+
+func TestForwardProxyRules(t *testing.T) {
+	target := startEcho(t)
+	_, port, _ := net.SplitHostPort(target)
+
+	// Test: Rule matching
+	p := &ForwardProxy{Allow: []string{"*.example.com", "api.other.org:443", "10.0.0.0/8"}, Deny: []string{"secret.example.com"}}
+	for dest, want := range map[string]bool{
+		"www.example.com:443":    true,
+		"example.com:443":        false,
+		"secret.example.com:443": false,
+		"api.other.org:443":      true,
+		"api.other.org:80":       false,
+		"10.1.2.3:22":            true,
+		"192.168.0.1:22":         false,
+	} {
+		assert.Equal(t, want, p.allowed(dest), dest)
+	}
+
+	// Test: Denied destinations are 403
+	p = &ForwardProxy{Allow: []string{"allowed.example:" + port}}
+	addr := startServer(t, p.Handler)
+	status, _, _ := do(t, addr, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\n")
+	assert.Equal(t, response.StatusForbidden, status)
+
+	// Test: A host name resolving into a denied CIDR is refused at dial time
+	p = &ForwardProxy{Deny: []string{"127.0.0.0/8"}}
+	addr = startServer(t, p.Handler)
+	status, _, _ = do(t, addr, "CONNECT localhost:"+port+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, response.StatusBadGateway, status)
+}
+
+func TestForwardProxyAuth(t *testing.T) {
+	target := startEcho(t)
+	p := &ForwardProxy{Credentials: auth.Users{"alice": "s3cret"}, Realm: "proxy"}
+	addr := startServer(t, p.Handler)
+
+	// Test: Missing or wrong credentials get 407 with a challenge
+	for _, header := range []string{"", "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("alice:wrong")) + "\r\n"} {
+		status, h, _ := do(t, addr, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n"+header+"\r\n")
+		assert.Equal(t, response.StatusProxyAuthRequired, status)
+		assert.Equal(t, `Basic realm="proxy", charset="UTF-8"`, h.Get("Proxy-Authenticate"))
+	}
+
+	// Test: Valid credentials open the tunnel
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	token := base64.StdEncoding.EncodeToString([]byte("alice:s3cret"))
+	_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\nProxy-Authorization: Basic %s\r\n\r\n", target, target, token)
+	require.NoError(t, err)
+	sl, _, err := response.ReadHead(bufio.NewReader(conn))
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusOK, sl.StatusCode)
+}
@@ -0,0 +1,120 @@
+// Package proxy forwards requests to other HTTP/1.1 servers, as a reverse proxy in
+// front of upstream services or as a forward proxy for clients
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// hopByHop lists the fields that only apply to a single connection (RFC 9110 Section 7.6.1)
+var hopByHop = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// cloneEndToEnd copies h without hop-by-hop fields, including any named in Connection
+func cloneEndToEnd(h headers.Headers) headers.Headers {
+	out := headers.NewHeaders()
+	for k, v := range h {
+		out[k] = v
+	}
+	for _, name := range strings.Split(h.Get("Connection"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out.Del(name)
+		}
+	}
+	for _, name := range hopByHop {
+		out.Del(name)
+	}
+	return out
+}
+
+// writeRequest sends a request with its buffered body on conn, asking to close afterwards
+func writeRequest(w io.Writer, method, target string, h headers.Headers, body []byte) error {
+	h.Set("Connection", "close")
+	h.Del("Content-Length")
+	out := &request.Request{
+		RequestLine: request.RequestLine{HttpVersion: "1.1", RequestTarget: target, Method: method},
+		Headers:     h,
+		Body:        body,
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// copyResponse relays an upstream response read from br to w, streaming the body
+func copyResponse(w io.Writer, br *bufio.Reader, method string, status response.StatusCode, h headers.Headers) error {
+	body, err := response.NewBodyReader(br, method, status, h)
+	if err != nil {
+		return err
+	}
+
+	if rw, ok := w.(server.ResponseWriter); ok {
+		out := rw.Header()
+		for k, v := range cloneEndToEnd(h) {
+			out.Set(k, v)
+		}
+		rw.WriteHeader(int(status))
+	}
+
+	// Each upstream read is flushed so slow or endless bodies reach the client as they arrive
+	flusher, _ := w.(server.ResponseWriter)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				if ferr := flusher.Flush(); ferr != nil {
+					return ferr
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// clientIP returns the host part of a request's remote address
+func clientIP(req *request.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// upstreamError maps a failure talking to an upstream to 504 for timeouts and 502 otherwise
+func upstreamError(ctx context.Context, err error) *server.HandlerError {
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &server.HandlerError{StatusCode: int(response.StatusGatewayTimeout), Message: "Gateway Timeout\n"}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &server.HandlerError{StatusCode: int(response.StatusGatewayTimeout), Message: "Gateway Timeout\n"}
+	}
+	return &server.HandlerError{StatusCode: int(response.StatusBadGateway), Message: "Bad Gateway\n"}
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing policies for ReverseProxy
+const (
+	RoundRobin       = "round-robin"
+	LeastConnections = "least-connections"
+)
+
+// Defaults for ReverseProxy fields left at zero
+const (
+	DefaultDialTimeout = 5 * time.Second
+	DefaultMaxFails    = 3
+	DefaultFailTimeout = 10 * time.Second
+)
+
+// Upstream is a backend server the proxy forwards to
+type Upstream struct {
+	Addr string // host:port
+
+	active    atomic.Int64
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+}
+
+// healthy reports whether the upstream is not marked down
+func (u *Upstream) healthy(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !now.Before(u.downUntil)
+}
+
+// ReverseProxy forwards requests to a pool of upstreams.
+// Upstreams that fail MaxFails times in a row are skipped for FailTimeout.
+// Response bodies are streamed, request bodies arrive decoded from the server, chunked
+// included, and are forwarded with a Content-Length
+type ReverseProxy struct {
+	Upstreams []*Upstream
+	Policy    string // RoundRobin or LeastConnections, defaults to RoundRobin
+
+	DialTimeout time.Duration
+	// ResponseTimeout bounds waiting for the upstream's response head, zero means no limit
+	ResponseTimeout time.Duration
+	MaxFails        int
+	FailTimeout     time.Duration
+	Logger          *slog.Logger
+
+	next atomic.Uint64
+}
+
+// NewReverseProxy creates a round-robin ReverseProxy for upstream addresses such as
+// "10.0.0.5:8080" or "http://10.0.0.5:8080"
+func NewReverseProxy(addrs ...string) (*ReverseProxy, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("proxy: at least one upstream is required")
+	}
+	p := &ReverseProxy{Policy: RoundRobin}
+	for _, addr := range addrs {
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "http://"), "/")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("proxy: upstream %q: %w", addr, err)
+		}
+		p.Upstreams = append(p.Upstreams, &Upstream{Addr: addr})
+	}
+	return p, nil
+}
+
+// Handler forwards req to an upstream, trying the next one if it can't be reached
+func (p *ReverseProxy) Handler(w io.Writer, req *request.Request) *server.HandlerError {
+	ctx := req.Context()
+	tried := map[*Upstream]bool{}
+	for {
+		u := p.pick(tried)
+		if u == nil {
+			return &server.HandlerError{StatusCode: int(response.StatusBadGateway), Message: "Bad Gateway: no healthy upstream\n"}
+		}
+		tried[u] = true
+
+		conn, err := p.dial(ctx, u)
+		if err != nil {
+			// Nothing was sent yet, so another upstream can safely take the request
+			p.logger().Error("Error dialing upstream", "upstream", u.Addr, "error", err)
+			p.fail(u)
+			if ctx.Err() != nil {
+				return upstreamError(ctx, err)
+			}
+			continue
+		}
+
+		handlerErr := p.forward(w, req, u, conn)
+		_ = conn.Close()
+		return handlerErr
+	}
+}
+
+// pick chooses a healthy upstream not tried yet according to the policy
+func (p *ReverseProxy) pick(tried map[*Upstream]bool) *Upstream {
+	now := time.Now()
+	n := len(p.Upstreams)
+	start := int(p.next.Add(1) - 1)
+
+	var best *Upstream
+	for i := 0; i < n; i++ {
+		u := p.Upstreams[(start+i)%n]
+		if tried[u] || !u.healthy(now) {
+			continue
+		}
+		if p.Policy != LeastConnections {
+			return u
+		}
+		if best == nil || u.active.Load() < best.active.Load() {
+			best = u
+		}
+	}
+	return best
+}
+
+// dial connects to an upstream
+func (p *ReverseProxy) dial(ctx context.Context, u *Upstream) (net.Conn, error) {
+	timeout := p.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultDialTimeout
+	}
+	d := net.Dialer{Timeout: timeout}
+	return d.DialContext(ctx, "tcp", u.Addr)
+}
+
+// forward sends the request on conn and relays the response
+func (p *ReverseProxy) forward(w io.Writer, req *request.Request, u *Upstream, conn net.Conn) *server.HandlerError {
+	ctx := req.Context()
+	u.active.Add(1)
+	defer u.active.Add(-1)
+
+	// Abort blocked reads and writes when the client goes away or the deadline passes
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if p.ResponseTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(p.ResponseTimeout))
+	}
+
+	h := cloneEndToEnd(req.Headers)
+	p.setForwarded(h, req)
+	h.Set("Host", u.Addr)
+	if err := writeRequest(conn, req.RequestLine.Method, req.RequestLine.RequestTarget, h, req.Body); err != nil {
+		p.logger().Error("Error writing to upstream", "upstream", u.Addr, "error", err)
+		p.fail(u)
+		return upstreamError(ctx, err)
+	}
+
+	br := bufio.NewReader(conn)
+	status, respHeaders, err := readFinalHead(br)
+	if err != nil {
+		p.logger().Error("Error reading upstream response", "upstream", u.Addr, "error", err)
+		p.fail(u)
+		return upstreamError(ctx, err)
+	}
+	p.succeed(u)
+
+	// The response timeout only covers the head, streaming bodies may take longer
+	if p.ResponseTimeout > 0 {
+		deadline, _ := ctx.Deadline()
+		_ = conn.SetReadDeadline(deadline)
+	}
+	if err := copyResponse(w, br, req.RequestLine.Method, status, respHeaders); err != nil {
+		p.logger().Error("Error relaying upstream response", "upstream", u.Addr, "error", err)
+		return upstreamError(ctx, err)
+	}
+	return nil
+}
+
+// setForwarded records the client in X-Forwarded-* and Forwarded (RFC 7239)
+func (p *ReverseProxy) setForwarded(h headers.Headers, req *request.Request) {
+	ip := clientIP(req)
+	if prior := req.Headers.Get("X-Forwarded-For"); prior != "" {
+		h.Set("X-Forwarded-For", prior+", "+ip)
+	} else {
+		h.Set("X-Forwarded-For", ip)
+	}
+	h.Set("X-Forwarded-Proto", "http")
+	if host := req.Headers.Get("Host"); host != "" {
+		h.Set("X-Forwarded-Host", host)
+	}
+
+	node := ip
+	if strings.Contains(ip, ":") {
+		node = `"[` + ip + `]"`
+	}
+	element := "for=" + node + ";proto=http"
+	if host, ok := quoteForwarded(req.Headers.Get("Host")); ok {
+		element += ";host=" + host
+	}
+	if prior := req.Headers.Get("Forwarded"); prior != "" {
+		element = prior + ", " + element
+	}
+	h.Set("Forwarded", element)
+}
+
+// quoteForwarded makes value a Forwarded quoted-string (RFC 7239 Section 4), escaping
+// backslashes and quotes. Empty values and ones with control characters are dropped
+func quoteForwarded(value string) (string, bool) {
+	if value == "" || strings.ContainsFunc(value, func(r rune) bool { return (r < ' ' && r != '\t') || r == 0x7f }) {
+		return "", false
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + value + `"`, true
+}
+
+// readFinalHead reads response heads until a final one, skipping 1xx interim responses
+func readFinalHead(br *bufio.Reader) (response.StatusCode, headers.Headers, error) {
+	for {
+		sl, h, err := response.ReadHead(br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if sl.StatusCode >= 200 || sl.StatusCode == response.StatusSwitchingProtocols {
+			return sl.StatusCode, h, nil
+		}
+	}
+}
+
+// fail counts a failure, marking the upstream down after MaxFails in a row
+func (p *ReverseProxy) fail(u *Upstream) {
+	maxFails, timeout := p.MaxFails, p.FailTimeout
+	if maxFails == 0 {
+		maxFails = DefaultMaxFails
+	}
+	if timeout == 0 {
+		timeout = DefaultFailTimeout
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures++
+	if u.failures >= maxFails {
+		u.failures = 0
+		u.downUntil = time.Now().Add(timeout)
+		p.logger().Warn("Upstream marked down", "upstream", u.Addr, "until", u.downUntil)
+	}
+}
+
+// succeed resets the failure count after the upstream answered
+func (p *ReverseProxy) succeed(u *Upstream) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures = 0
+}
+
+// logger returns the configured Logger or the slog default
+func (p *ReverseProxy) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
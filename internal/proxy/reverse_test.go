@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handler on a random local port and returns its address
+func startServer(t *testing.T, handler server.Handler) string {
+	t.Helper()
+	srv := &server.Server{Handler: handler}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	return fmt.Sprintf("127.0.0.1:%d", srv.Listener.Addr().(*net.TCPAddr).Port)
+}
+
+// closedAddr returns an address nothing is listening on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+// do sends a raw request to addr and reads the response with the response package
+func do(t *testing.T, addr, raw string) (response.StatusCode, headers.Headers, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, raw)
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	sl, h, err := response.ReadHead(br)
+	require.NoError(t, err)
+	body, err := response.NewBodyReader(br, strings.Fields(raw)[0], sl.StatusCode, h)
+	require.NoError(t, err)
+	b, err := io.ReadAll(body)
+	require.NoError(t, err)
+	return sl.StatusCode, h, string(b)
+}
+
+// echo reports what the upstream received
+func echo(name string) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw := w.(server.ResponseWriter)
+		rw.Header().Set("X-Upstream", name)
+		rw.Header().Set("Connection", "X-Internal")
+		rw.Header().Set("X-Internal", "secret")
+		rw.WriteHeader(201)
+		for _, name := range []string{"Host", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded", "X-Hop", "Proxy-Authorization"} {
+			fmt.Fprintf(w, "%s=%s\n", name, req.Headers.Get(name))
+		}
+		fmt.Fprintf(w, "%s %s %s", req.RequestLine.Method, req.RequestLine.RequestTarget, req.Body)
+		return nil
+	}
+}
+
+func TestReverseProxy(t *testing.T) {
+	upstream := startServer(t, echo("a"))
+	p, err := NewReverseProxy("http://" + upstream)
+	require.NoError(t, err)
+	addr := startServer(t, p.Handler)
+
+	// Test: Request and response are relayed with forwarding headers, without hop-by-hop fields
+	status, h, body := do(t, addr, "POST /orders?id=1 HTTP/1.1\r\nHost: edge.example\r\nConnection: X-Hop\r\nX-Hop: drop me\r\nProxy-Authorization: Basic eA==\r\nForwarded: for=198.51.100.1\r\nContent-Length: 5\r\n\r\nhello")
+	assert.Equal(t, response.StatusCode(201), status)
+	assert.Equal(t, "a", h.Get("X-Upstream"))
+	assert.Empty(t, h.Get("X-Internal"))
+	assert.Equal(t, strings.Join([]string{
+		"Host=" + upstream,
+		"X-Forwarded-For=127.0.0.1",
+		"X-Forwarded-Proto=http",
+		"X-Forwarded-Host=edge.example",
+		`Forwarded=for=198.51.100.1, for=127.0.0.1;proto=http;host="edge.example"`,
+		"X-Hop=",
+		"Proxy-Authorization=",
+		"POST /orders?id=1 hello",
+	}, "\n"), body)
+
+	// Test: Chunked uploads reach the upstream decoded
+	_, _, body = do(t, addr, "POST /upload HTTP/1.1\r\nHost: edge.example\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n4\r\ndefg\r\n0\r\n\r\n")
+	assert.True(t, strings.HasSuffix(body, "POST /upload abcdefg"), body)
+
+	// Test: The Host is escaped in Forwarded so it can't add parameters or elements
+	_, _, body = do(t, addr, "GET / HTTP/1.1\r\nHost: a\"; for=evil, by=\\x\r\n\r\n")
+	assert.Contains(t, body, `Forwarded=for=127.0.0.1;proto=http;host="a\"; for=evil, by=\\x"`+"\n")
+
+	// Test: HEAD keeps the upstream Content-Length
+	upstream = startServer(t, func(w io.Writer, req *request.Request) *server.HandlerError {
+		_, _ = w.Write([]byte("twelve bytes"))
+		return nil
+	})
+	p, err = NewReverseProxy(upstream)
+	require.NoError(t, err)
+	addr = startServer(t, p.Handler)
+	_, h, body = do(t, addr, "HEAD / HTTP/1.1\r\nHost: edge.example\r\n\r\n")
+	assert.Empty(t, body)
+	assert.Equal(t, "12", h.Get("Content-Length"))
+}
+
+func TestReverseProxyStreaming(t *testing.T) {
+	// Test: A chunked upstream body is streamed through as it is flushed
+	release := make(chan struct{})
+	upstream := startServer(t, func(w io.Writer, req *request.Request) *server.HandlerError {
+		rw := w.(server.ResponseWriter)
+		_, _ = w.Write([]byte("first,"))
+		_ = rw.Flush()
+		<-release
+		_, _ = w.Write([]byte("second"))
+		return nil
+	})
+	p, err := NewReverseProxy(upstream)
+	require.NoError(t, err)
+	addr := startServer(t, p.Handler)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "GET /stream HTTP/1.1\r\nHost: edge\r\n\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	sl, h, err := response.ReadHead(br)
+	require.NoError(t, err)
+	assert.Equal(t, "chunked", h.Get("Transfer-Encoding"))
+	body, err := response.NewBodyReader(br, "GET", sl.StatusCode, h)
+	require.NoError(t, err)
+
+	first := make([]byte, len("first,"))
+	_, err = io.ReadFull(body, first)
+	require.NoError(t, err)
+	assert.Equal(t, "first,", string(first))
+	close(release)
+	rest, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(rest))
+}
+
+func TestReverseProxyBalancing(t *testing.T) {
+	a, b := startServer(t, echo("a")), startServer(t, echo("b"))
+	p, err := NewReverseProxy(a, b)
+	require.NoError(t, err)
+	addr := startServer(t, p.Handler)
+
+	// Test: Round robin alternates
+	var seen []string
+	for i := 0; i < 4; i++ {
+		_, h, _ := do(t, addr, "GET / HTTP/1.1\r\nHost: edge\r\n\r\n")
+		seen = append(seen, h.Get("X-Upstream"))
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, seen)
+
+	// Test: Least connections prefers the idle upstream
+	p.Policy = LeastConnections
+	p.Upstreams[0].active.Store(3)
+	assert.Equal(t, p.Upstreams[1], p.pick(map[*Upstream]bool{}))
+	p.Upstreams[0].active.Store(0)
+}
+
+func TestReverseProxyFailures(t *testing.T) {
+	up := startServer(t, echo("up"))
+	down := closedAddr(t)
+	p, err := NewReverseProxy(down, up)
+	require.NoError(t, err)
+	p.MaxFails = 2
+	addr := startServer(t, p.Handler)
+
+	// Test: An unreachable upstream is skipped, then marked down
+	for i := 0; i < 4; i++ {
+		status, h, _ := do(t, addr, "GET / HTTP/1.1\r\nHost: edge\r\n\r\n")
+		assert.Equal(t, response.StatusCode(201), status)
+		assert.Equal(t, "up", h.Get("X-Upstream"))
+	}
+	assert.False(t, p.Upstreams[0].healthy(time.Now()))
+	assert.True(t, p.Upstreams[1].healthy(time.Now()))
+
+	// Test: No reachable upstream is 502
+	p, err = NewReverseProxy(closedAddr(t))
+	require.NoError(t, err)
+	addr = startServer(t, p.Handler)
+	status, _, _ := do(t, addr, "GET / HTTP/1.1\r\nHost: edge\r\n\r\n")
+	assert.Equal(t, response.StatusBadGateway, status)
+
+	// Test: An upstream that never answers is 504
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	p, err = NewReverseProxy(silent.Addr().String())
+	require.NoError(t, err)
+	p.ResponseTimeout = 100 * time.Millisecond
+	addr = startServer(t, p.Handler)
+	status, _, _ = do(t, addr, "GET / HTTP/1.1\r\nHost: edge\r\n\r\n")
+	assert.Equal(t, response.StatusGatewayTimeout, status)
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// State is the per-key data an Algorithm keeps in a Store
+type State struct {
+	Value    float64   // Tokens left, or requests in the current window
+	Previous float64   // Requests in the previous window
+	Stamp    time.Time // Last refill, or start of the current window
+}
+
+// Result describes the outcome of one request against a key's quota
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Until the quota is fully available again
+	RetryAfter time.Duration // Until a denied request could succeed
+}
+
+// Algorithm decides whether a request fits the quota, updating the key's state
+type Algorithm interface {
+	Take(s *State, now time.Time) Result
+	// IdleTTL is how long unused state must be kept before it is equivalent to none
+	IdleTTL() time.Duration
+	// Validate reports a configuration Take can't work with
+	Validate() error
+}
+
+// TokenBucket allows Limit requests per Period on average, with bursts of up to Burst
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	// Burst is the bucket capacity, zero uses Limit
+	Burst int
+}
+
+func (b TokenBucket) capacity() float64 {
+	if b.Burst > 0 {
+		return float64(b.Burst)
+	}
+	return float64(b.Limit)
+}
+
+// rate returns the refill rate in tokens per second
+func (b TokenBucket) rate() float64 {
+	return float64(b.Limit) / b.Period.Seconds()
+}
+
+func (b TokenBucket) Take(s *State, now time.Time) Result {
+	capacity, rate := b.capacity(), b.rate()
+	if s.Stamp.IsZero() {
+		s.Value = capacity
+	} else if elapsed := now.Sub(s.Stamp).Seconds(); elapsed > 0 {
+		s.Value = math.Min(capacity, s.Value+elapsed*rate)
+	}
+	s.Stamp = now
+
+	res := Result{Limit: int(capacity)}
+	if s.Value >= 1 {
+		s.Value--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - s.Value) / rate)
+	}
+	res.Remaining = int(s.Value)
+	res.Reset = seconds((capacity - s.Value) / rate)
+	return res
+}
+
+func (b TokenBucket) IdleTTL() time.Duration {
+	return seconds(b.capacity() / b.rate())
+}
+
+func (b TokenBucket) Validate() error {
+	if b.Limit <= 0 || b.Period <= 0 || b.Burst < 0 {
+		return errors.New("ratelimit: token bucket needs a positive Limit and Period")
+	}
+	return nil
+}
+
+// SlidingWindow allows Limit requests in any Window, estimating the count from the
+// current and previous fixed windows weighted by their overlap
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (w SlidingWindow) Take(s *State, now time.Time) Result {
+	current := now.Truncate(w.Window)
+	if !s.Stamp.Equal(current) {
+		if s.Stamp.Equal(current.Add(-w.Window)) {
+			s.Previous = s.Value
+		} else {
+			s.Previous = 0
+		}
+		s.Value = 0
+		s.Stamp = current
+	}
+
+	elapsed := now.Sub(current)
+	weight := 1 - float64(elapsed)/float64(w.Window)
+	limit := float64(w.Limit)
+
+	res := Result{Limit: w.Limit, Reset: w.Window - elapsed}
+	if s.Previous*weight+s.Value+1 <= limit {
+		s.Value++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = w.retryAfter(s, elapsed)
+	}
+	res.Remaining = max(int(limit-(s.Previous*weight+s.Value)), 0)
+	return res
+}
+
+// retryAfter finds when the weighted count leaves room for one more request
+func (w SlidingWindow) retryAfter(s *State, elapsed time.Duration) time.Duration {
+	limit := float64(w.Limit)
+	// Later in this window, once the previous window's share has decayed enough
+	if room := limit - s.Value - 1; room >= 0 && s.Previous > 0 {
+		at := time.Duration((1 - room/s.Previous) * float64(w.Window))
+		return (at - elapsed).Round(time.Millisecond)
+	}
+	// Otherwise in the next window, where this window's count becomes the decaying one
+	at := w.Window
+	if s.Value > 0 {
+		at += time.Duration(math.Max(0, 1-(limit-1)/s.Value) * float64(w.Window))
+	}
+	return (at - elapsed).Round(time.Millisecond)
+}
+
+func (w SlidingWindow) IdleTTL() time.Duration {
+	return 2 * w.Window
+}
+
+func (w SlidingWindow) Validate() error {
+	if w.Limit <= 0 || w.Window <= 0 {
+		return errors.New("ratelimit: sliding window needs a positive Limit and Window")
+	}
+	return nil
+}
+
+// seconds converts fractional seconds to a Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,111 @@
+// Package ratelimit limits how often a client may call the server, keyed by IP,
+// API key or route, answering 429 Too Many Requests once the quota is used up
+package ratelimit
+
+import (
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc returns the quota a request counts against, "" exempts the request
+type KeyFunc func(req *request.Request) string
+
+// ByIP keys requests by the client's IP address
+func ByIP(req *request.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// ByHeader keys requests by a header value such as an API key. Requests without it count
+// against their client IP, or share one quota when that is unknown, so leaving the header
+// out doesn't bypass the limit. The value must come from a trusted source, e.g. set by an
+// authenticating proxy or checked by earlier middleware, as a client sending a new value
+// with each request gets a fresh quota every time
+func ByHeader(name string) KeyFunc {
+	return func(req *request.Request) string {
+		value := req.Headers.Get(name)
+		if value != "" {
+			return name + ":" + value
+		}
+		if ip := ByIP(req); ip != "" {
+			return ip
+		}
+		return name + ":"
+	}
+}
+
+// ByRoute keys requests by method and path, sharing one quota between all clients
+func ByRoute(req *request.Request) string {
+	path, _, _ := strings.Cut(req.RequestLine.RequestTarget, "?")
+	return req.RequestLine.Method + " " + path
+}
+
+// Limiter applies an Algorithm to requests grouped by Key
+type Limiter struct {
+	Algorithm Algorithm
+	Key       KeyFunc
+	Store     Store
+	// Now returns the current time for the Algorithm and Store, defaults to time.Now
+	Now func() time.Time
+}
+
+// New validates alg and creates a Limiter backed by a MemoryStore
+func New(alg Algorithm, key KeyFunc) (*Limiter, error) {
+	if err := alg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Limiter{Algorithm: alg, Key: key, Store: NewMemoryStore()}, nil
+}
+
+// Middleware counts each request and rejects it with 429 once its key is over quota.
+// Every limited response carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+func (l *Limiter) Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		key := l.Key(req)
+		if key == "" {
+			return next(w, req)
+		}
+
+		now := time.Now()
+		if l.Now != nil {
+			now = l.Now()
+		}
+		var res Result
+		err := l.Store.Update(req.Context(), key, now, l.Algorithm.IdleTTL(), func(s *State) {
+			res = l.Algorithm.Take(s, now)
+		})
+		if err != nil {
+			return &server.HandlerError{StatusCode: int(response.StatusServiceUnavailable), Message: fmt.Sprintf("Rate limiter unavailable: %v\n", err)}
+		}
+
+		if rw, ok := w.(server.ResponseWriter); ok {
+			h := rw.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			}
+		}
+		if !res.Allowed {
+			return &server.HandlerError{StatusCode: int(response.StatusTooManyRequests), Message: "Too Many Requests\n"}
+		}
+		return next(w, req)
+	}
+}
+
+// ceilSeconds formats a duration as whole seconds, rounding up so clients don't retry early
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
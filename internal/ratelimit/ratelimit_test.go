@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// t0 is aligned to a minute so fixed windows start there
+var t0 = time.Unix(1_800_000_000, 0)
+
+func TestTokenBucket(t *testing.T) {
+	b := TokenBucket{Limit: 2, Period: time.Second, Burst: 4}
+	var s State
+
+	// Test: A full bucket allows a burst
+	for i := 3; i >= 0; i-- {
+		res := b.Take(&s, t0)
+		require.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 4, res.Limit)
+	}
+
+	// Test: An empty bucket refills at Limit per Period
+	res := b.Take(&s, t0)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.Reset)
+	res = b.Take(&s, t0.Add(500*time.Millisecond))
+	assert.True(t, res.Allowed)
+
+	// Test: Refill stops at the burst size
+	res = b.Take(&s, t0.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Remaining)
+	assert.Equal(t, 2*time.Second, b.IdleTTL())
+}
+
+func TestSlidingWindow(t *testing.T) {
+	w := SlidingWindow{Limit: 10, Window: time.Minute}
+	var s State
+
+	// Test: The limit applies within a window
+	for i := 0; i < 10; i++ {
+		require.True(t, w.Take(&s, t0).Allowed)
+	}
+	res := w.Take(&s, t0.Add(10*time.Second))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 50*time.Second, res.Reset)
+	// The previous window must decay to 9 of 10, six seconds into the next one
+	assert.Equal(t, 56*time.Second, res.RetryAfter)
+
+	// Test: The previous window counts by its remaining overlap
+	assert.False(t, w.Take(&s, t0.Add(65*time.Second)).Allowed)
+	assert.True(t, w.Take(&s, t0.Add(66*time.Second)).Allowed)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if w.Take(&s, t0.Add(90*time.Second)).Allowed {
+			allowed++
+		}
+	}
+	assert.Equal(t, 4, allowed, "half of the previous 10 plus 1 already taken leaves 4")
+
+	// Test: Retry later in the same window once the previous one decays to 4 of 10
+	res = w.Take(&s, t0.Add(90*time.Second))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 6*time.Second, res.RetryAfter)
+	assert.True(t, w.Take(&s, t0.Add(96*time.Second)).Allowed)
+
+	// Test: Old windows are forgotten
+	res = w.Take(&s, t0.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 9, res.Remaining)
+}
+
+func TestMemoryStore(t *testing.T) {
+	m := NewMemoryStore()
+	m.SweepInterval = 0
+	ctx := t.Context()
+
+	// Test: State persists per key until its ttl passes
+	inc := func(s *State) { s.Value++ }
+	require.NoError(t, m.Update(ctx, "a", t0, time.Hour, inc))
+	var seen float64
+	require.NoError(t, m.Update(ctx, "a", t0, time.Hour, func(s *State) { seen = s.Value }))
+	assert.Equal(t, float64(1), seen)
+
+	// Test: Idle keys are evicted, going by the time passed in
+	require.NoError(t, m.Update(ctx, "b", t0, time.Second, inc))
+	require.NoError(t, m.Update(ctx, "c", t0.Add(time.Minute), time.Hour, inc))
+	assert.Equal(t, 2, m.Len())
+	require.NoError(t, m.Update(ctx, "a", t0.Add(2*time.Hour), time.Hour, func(s *State) { seen = s.Value }))
+	assert.Equal(t, float64(0), seen)
+	assert.Equal(t, 1, m.Len())
+
+	// Test: The zero value is ready to use
+	var zero MemoryStore
+	assert.Equal(t, 0, zero.Len())
+	require.NoError(t, zero.Update(ctx, "a", t0, time.Hour, inc))
+	assert.Equal(t, 1, zero.Len())
+}
+
+func TestMiddleware(t *testing.T) {
+	now := t0
+	l, err := New(TokenBucket{Limit: 1, Period: time.Minute}, ByHeader("X-Api-Key"))
+	require.NoError(t, err)
+	l.Now = func() time.Time { return now }
+	h := l.Middleware(func(w io.Writer, req *request.Request) *server.HandlerError {
+		_, _ = w.Write([]byte("ok"))
+		return nil
+	})
+	call := func(apiKey string) (*response.Writer, *server.HandlerError) {
+		raw := "GET /items HTTP/1.1\r\nHost: api\r\n"
+		if apiKey != "" {
+			raw += "X-Api-Key: " + apiKey + "\r\n"
+		}
+		req, err := request.RequestFromReader(strings.NewReader(raw + "\r\n"))
+		require.NoError(t, err)
+		req.RemoteAddr = "203.0.113.7:51234"
+		w := response.NewWriter(&bytes.Buffer{})
+		return w, h(w, req)
+	}
+
+	// Test: Allowed requests carry RateLimit headers
+	w, herr := call("k1")
+	require.Nil(t, herr)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	// Test: Over quota is 429 with Retry-After
+	w, herr = call("k1")
+	require.NotNil(t, herr)
+	assert.Equal(t, 429, herr.StatusCode)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Test: Keys have separate quotas, requests without a key count against their IP
+	_, herr = call("k2")
+	assert.Nil(t, herr)
+	w, herr = call("")
+	assert.Nil(t, herr)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	_, herr = call("")
+	require.NotNil(t, herr)
+	assert.Equal(t, 429, herr.StatusCode)
+
+	// Test: Key functions
+	req, err := request.RequestFromReader(strings.NewReader("POST /items?page=2 HTTP/1.1\r\nHost: api\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "X-Api-Key:", ByHeader("X-Api-Key")(req))
+	req.RemoteAddr = "203.0.113.7:51234"
+	assert.Equal(t, "203.0.113.7", ByIP(req))
+	assert.Equal(t, "203.0.113.7", ByHeader("X-Api-Key")(req))
+	assert.Equal(t, "POST /items", ByRoute(req))
+
+	// Test: Algorithms that can't work are rejected
+	for _, alg := range []Algorithm{TokenBucket{Limit: 1}, TokenBucket{Period: time.Second}, SlidingWindow{Limit: 1}} {
+		_, err = New(alg, ByIP)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds rate limit state per key. Update must apply fn atomically for a key, so
+// a store shared between servers needs a transaction or compare-and-swap around it.
+// State idle for ttl after now may be forgotten
+type Store interface {
+	Update(ctx context.Context, key string, now time.Time, ttl time.Duration, fn func(s *State)) error
+}
+
+// DefaultSweepInterval is how often MemoryStore evicts idle keys
+const DefaultSweepInterval = time.Minute
+
+// MemoryStore is a Store in process memory. Keys idle for longer than their ttl are
+// evicted on a later Update, so memory stays bounded by the number of active clients.
+// The zero value is ready to use
+type MemoryStore struct {
+	SweepInterval time.Duration // Zero sweeps on every Update
+
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore sweeping every DefaultSweepInterval
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{SweepInterval: DefaultSweepInterval, entries: map[string]*memoryEntry{}}
+}
+
+func (m *MemoryStore) Update(_ context.Context, key string, now time.Time, ttl time.Duration, fn func(s *State)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = map[string]*memoryEntry{}
+	}
+	if now.Sub(m.lastSweep) >= m.SweepInterval {
+		m.sweep(now)
+	}
+
+	entry, ok := m.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+// sweep removes expired entries, the caller holds mu
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Len returns the number of keys currently tracked
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
@@ -0,0 +1,59 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// ErrUnexpectedContentType is returned when the body is not of the media type a parser expects
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// MediaType returns the request's Content-Type without parameters, lowercased
+func (r *Request) MediaType() string {
+	mediaType, _, err := mime.ParseMediaType(r.Headers.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Query parses the query string of the request target into a multi-valued map
+func (r *Request) Query() (url.Values, error) {
+	_, query, _ := strings.Cut(r.RequestLine.RequestTarget, "?")
+	query, _, _ = strings.Cut(query, "#")
+	return url.ParseQuery(query)
+}
+
+// PostForm parses an application/x-www-form-urlencoded body into a multi-valued map
+func (r *Request) PostForm() (url.Values, error) {
+	if r.MediaType() != "application/x-www-form-urlencoded" {
+		return nil, fmt.Errorf("%w: %s - expected application/x-www-form-urlencoded", ErrUnexpectedContentType, r.Headers.Get("Content-Type"))
+	}
+	return url.ParseQuery(string(r.Body))
+}
+
+// Form merges the urlencoded body, if any, with the query string. Body values come first
+func (r *Request) Form() (url.Values, error) {
+	form := url.Values{}
+	if r.MediaType() == "application/x-www-form-urlencoded" {
+		body, err := r.PostForm()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range body {
+			form[k] = append(form[k], v...)
+		}
+	}
+
+	query, err := r.Query()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range query {
+		form[k] = append(form[k], v...)
+	}
+	return form, nil
+}
@@ -0,0 +1,137 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForm(t *testing.T) {
+	// Test: Query and urlencoded body merge, body first
+	r, err := RequestFromReader(strings.NewReader("POST /submit?a=query&b=2 HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/x-www-form-urlencoded; charset=utf-8\r\nContent-Length: 20\r\n\r\na=body&c=hello+there"))
+	require.NoError(t, err)
+	form, err := r.Form()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"body", "query"}, form["a"])
+	assert.Equal(t, "2", form.Get("b"))
+	assert.Equal(t, "hello there", form.Get("c"))
+
+	// Test: Query alone
+	query, err := r.Query()
+	require.NoError(t, err)
+	assert.Equal(t, "query", query.Get("a"))
+
+	// Test: PostForm rejects other media types
+	r, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 2\r\n\r\n{}"))
+	require.NoError(t, err)
+	_, err = r.PostForm()
+	assert.ErrorIs(t, err, ErrUnexpectedContentType)
+
+	// Test: Form without a urlencoded body still returns the query
+	form, err = r.Form()
+	require.NoError(t, err)
+	assert.Empty(t, form)
+}
+
+const testMultipartBody = "preamble\r\n" +
+	"--XyZ\r\n" +
+	"Content-Disposition: form-data; name=\"title\"\r\n" +
+	"\r\n" +
+	"hello\r\n" +
+	"--XyZ\r\n" +
+	"Content-Disposition: form-data; name=\"upload\"; filename=\"C:\\\\docs\\\\a.txt\"\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"first file\r\nwith a line that looks like --XyZ but isn't\r\n" +
+	"--XyZ\r\n" +
+	"Content-Disposition: form-data; name=\"upload\"; filename=\"b.bin\"\r\n" +
+	"\r\n" +
+	"0123456789abcdef\r\n" +
+	"--XyZ--\r\n" +
+	"epilogue"
+
+func TestMultipartReader(t *testing.T) {
+	// Test: Parts stream with their headers, read a few bytes at a time
+	mr := NewMultipartReader(&chunkReader{data: testMultipartBody, numBytesPerRead: 3}, "XyZ")
+	part, err := mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "title", part.FormName())
+	assert.Equal(t, "", part.FileName())
+	body, err := io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	part, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "upload", part.FormName())
+	assert.Equal(t, "a.txt", part.FileName())
+	assert.Equal(t, "text/plain", part.Headers.Get("Content-Type"))
+	body, err = io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "first file\r\nwith a line that looks like --XyZ but isn't", string(body))
+
+	// Test: Unread content is skipped by NextPart
+	part, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "b.bin", part.FileName())
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Part count limit
+	mr = NewMultipartReader(strings.NewReader(testMultipartBody), "XyZ")
+	mr.MaxParts = 2
+	_, err = mr.ReadForm(1 << 20)
+	assert.ErrorIs(t, err, ErrMultipartLimit)
+
+	// Test: Part size limit
+	mr = NewMultipartReader(strings.NewReader(testMultipartBody), "XyZ")
+	mr.MaxPartSize = 8
+	_, err = mr.ReadForm(1 << 20)
+	assert.ErrorIs(t, err, ErrMultipartLimit)
+
+	// Test: Missing closing boundary
+	mr = NewMultipartReader(strings.NewReader("--XyZ\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\ntruncated"), "XyZ")
+	part, err = mr.NextPart()
+	require.NoError(t, err)
+	_, err = io.ReadAll(part)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestParseMultipartForm(t *testing.T) {
+	raw := "POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Type: multipart/form-data; boundary=XyZ\r\n" +
+		"Content-Length: " + strconv.Itoa(len(testMultipartBody)) + "\r\n\r\n" + testMultipartBody
+	r, err := RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	// Test: The first file fits in memory, the second spills to a temp file
+	form, err := r.ParseMultipartForm(60)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, form.RemoveAll()) }()
+
+	assert.Equal(t, "hello", form.Value.Get("title"))
+	files := form.File["upload"]
+	require.Len(t, files, 2)
+	assert.Empty(t, files[0].tmpfile)
+	assert.NotEmpty(t, files[1].tmpfile)
+	assert.Equal(t, int64(16), files[1].Size)
+
+	for i, want := range []string{"first file\r\nwith a line that looks like --XyZ but isn't", "0123456789abcdef"} {
+		f, err := files[i].Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		assert.Equal(t, want, string(content))
+	}
+
+	// Test: Not a multipart request
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, err = r.ParseMultipartForm(1 << 20)
+	assert.ErrorIs(t, err, ErrUnexpectedContentType)
+}
@@ -0,0 +1,353 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Default limits for a MultipartReader
+const (
+	DefaultMaxParts       = 1000
+	DefaultMaxPartSize    = 32 << 20
+	maxPartHeaderBytes    = 16 << 10
+	multipartBufferLength = 64 << 10
+)
+
+// ErrMultipartLimit is returned when a multipart body exceeds the part count or size limits
+var ErrMultipartLimit = errors.New("multipart limit exceeded")
+
+// MultipartReader streams the parts of a multipart/form-data body (RFC 7578)
+type MultipartReader struct {
+	// MaxParts limits the number of parts, MaxPartSize the bytes in each
+	MaxParts    int
+	MaxPartSize int64
+
+	br        *bufio.Reader
+	dash      string // "--boundary", starts each delimiter line
+	delimiter []byte // "\r\n--boundary", ends each part's content
+	parts     int
+	current   *Part
+	done      bool
+}
+
+// Part is a single part of a multipart body, reading it yields the part's content
+type Part struct {
+	Headers headers.Headers
+
+	mr     *MultipartReader
+	read   int64
+	done   bool
+	params map[string]string
+}
+
+// MultipartReader returns a reader over the request's multipart/form-data body
+func (r *Request) MultipartReader() (*MultipartReader, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Headers.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, fmt.Errorf("%w: %s - expected multipart/form-data", ErrUnexpectedContentType, r.Headers.Get("Content-Type"))
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("%w: missing multipart boundary", ErrUnexpectedContentType)
+	}
+	return NewMultipartReader(bytes.NewReader(r.Body), boundary), nil
+}
+
+// NewMultipartReader creates a reader over a multipart body with the given boundary
+func NewMultipartReader(r io.Reader, boundary string) *MultipartReader {
+	return &MultipartReader{
+		MaxParts:    DefaultMaxParts,
+		MaxPartSize: DefaultMaxPartSize,
+		br:          bufio.NewReaderSize(r, multipartBufferLength),
+		dash:        "--" + boundary,
+		delimiter:   []byte("\r\n--" + boundary),
+	}
+}
+
+// NextPart skips the rest of the current part and returns the next one, or io.EOF after the last
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	if mr.done {
+		return nil, io.EOF
+	}
+
+	if mr.current == nil {
+		// Skip the preamble up to the first delimiter line
+		for {
+			line, err := mr.br.ReadString('\n')
+			if strings.TrimRight(line, " \t\r\n") == mr.dash {
+				break
+			}
+			if strings.TrimRight(line, " \t\r\n") == mr.dash+"--" {
+				mr.done = true
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("multipart: no initial boundary: %w", io.ErrUnexpectedEOF)
+			}
+		}
+	} else {
+		if _, err := io.Copy(io.Discard, mr.current); err != nil {
+			return nil, err
+		}
+
+		// The current part ended at "\r\n--boundary", followed by "--" for the last part
+		if _, err := mr.br.Discard(2); err != nil {
+			return nil, fmt.Errorf("multipart: reading boundary: %w", io.ErrUnexpectedEOF)
+		}
+		line, err := mr.br.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && strings.HasSuffix(line, "--")) {
+			return nil, fmt.Errorf("multipart: reading boundary: %w", io.ErrUnexpectedEOF)
+		}
+		rest := strings.TrimRight(strings.TrimPrefix(line, mr.dash), " \t\r\n")
+		if rest == "--" {
+			mr.done = true
+			return nil, io.EOF
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("multipart: malformed boundary line %q", line)
+		}
+	}
+
+	mr.parts++
+	if mr.parts > mr.MaxParts {
+		return nil, fmt.Errorf("%w: more than %d parts", ErrMultipartLimit, mr.MaxParts)
+	}
+
+	h, err := mr.readPartHeaders()
+	if err != nil {
+		return nil, err
+	}
+	mr.current = &Part{Headers: h, mr: mr}
+	return mr.current, nil
+}
+
+// readPartHeaders reads a part's header block using the same parser as request headers
+func (mr *MultipartReader) readPartHeaders() (headers.Headers, error) {
+	var block []byte
+	for {
+		line, err := mr.br.ReadSlice('\n')
+		if err != nil {
+			return nil, fmt.Errorf("multipart: reading part headers: %w", io.ErrUnexpectedEOF)
+		}
+		block = append(block, line...)
+		if len(block) > maxPartHeaderBytes {
+			return nil, fmt.Errorf("%w: part headers too large", ErrMultipartLimit)
+		}
+		if string(line) == "\r\n" {
+			break
+		}
+	}
+
+	h := headers.NewHeaders()
+	for {
+		n, done, err := h.Parse(block)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return h, nil
+		}
+		block = block[n:]
+	}
+}
+
+// Read reads the part's content, stopping at the next delimiter
+func (p *Part) Read(b []byte) (int, error) {
+	if p.done {
+		return 0, io.EOF
+	}
+
+	br := p.mr.br
+	peek, err := br.Peek(br.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return 0, err
+	}
+
+	// Only hand out bytes that can't be the start of a delimiter
+	available := len(peek)
+	if i := bytes.Index(peek, p.mr.delimiter); i >= 0 {
+		available = i
+		p.done = i <= len(b)
+	} else if errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("multipart: missing closing boundary: %w", io.ErrUnexpectedEOF)
+	} else {
+		available = max(len(peek)-len(p.mr.delimiter)+1, 0)
+	}
+
+	n := copy(b, peek[:available])
+	_, _ = br.Discard(n)
+	p.read += int64(n)
+	if p.read > p.mr.MaxPartSize {
+		return n, fmt.Errorf("%w: part larger than %d bytes", ErrMultipartLimit, p.mr.MaxPartSize)
+	}
+	if p.done && n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// FormName returns the name parameter of the part's Content-Disposition
+func (p *Part) FormName() string {
+	return p.dispositionParams()["name"]
+}
+
+// FileName returns the filename parameter of the part's Content-Disposition, without any directory
+func (p *Part) FileName() string {
+	name := p.dispositionParams()["filename"]
+	if name == "" {
+		return ""
+	}
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	return name
+}
+
+// dispositionParams parses and caches the Content-Disposition parameters
+func (p *Part) dispositionParams() map[string]string {
+	if p.params == nil {
+		disposition, params, err := mime.ParseMediaType(p.Headers.Get("Content-Disposition"))
+		if err != nil || disposition != "form-data" {
+			params = map[string]string{}
+		}
+		p.params = params
+	}
+	return p.params
+}
+
+// MultipartForm holds a parsed multipart form, file parts may be backed by temp files
+type MultipartForm struct {
+	Value url.Values
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes a file part of a multipart form
+type FileHeader struct {
+	Filename string
+	Headers  headers.Headers
+	Size     int64
+
+	content []byte
+	tmpfile string
+}
+
+// ReadForm reads every part, keeping up to maxMemory bytes of file content in memory
+// and spilling the rest to temp files. Call RemoveAll on the form to clean them up
+func (mr *MultipartReader) ReadForm(maxMemory int64) (_ *MultipartForm, err error) {
+	form := &MultipartForm{Value: url.Values{}, File: map[string][]*FileHeader{}}
+	defer func() {
+		if err != nil {
+			_ = form.RemoveAll()
+		}
+	}()
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return nil, err
+			}
+			form.Value.Add(name, string(value))
+			continue
+		}
+
+		fh := &FileHeader{Filename: filename, Headers: part.Headers}
+		var buf bytes.Buffer
+		// Read one byte past the budget to find out whether the part fits
+		n, err := io.CopyN(&buf, part, maxMemory+1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if n > maxMemory {
+			fh.Size, err = spillToFile(fh, &buf, part)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			fh.content = buf.Bytes()
+			fh.Size = n
+			maxMemory -= n
+		}
+		form.File[name] = append(form.File[name], fh)
+	}
+}
+
+// spillToFile writes the buffered prefix and the rest of a part to a temp file
+func spillToFile(fh *FileHeader, prefix io.Reader, rest io.Reader) (int64, error) {
+	f, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return 0, err
+	}
+	fh.tmpfile = f.Name()
+
+	size, err := io.Copy(f, io.MultiReader(prefix, rest))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(fh.tmpfile)
+		fh.tmpfile = ""
+		return 0, err
+	}
+	return size, nil
+}
+
+// ParseMultipartForm reads the request's multipart/form-data body, see MultipartReader.ReadForm
+func (r *Request) ParseMultipartForm(maxMemory int64) (*MultipartForm, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return mr.ReadForm(maxMemory)
+}
+
+// Open returns the file's content, from memory or its temp file
+func (fh *FileHeader) Open() (io.ReadSeekCloser, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return nopCloser{bytes.NewReader(fh.content)}, nil
+}
+
+// nopCloser adds a no-op Close to an in-memory reader
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// RemoveAll deletes any temp files backing the form's file parts
+func (f *MultipartForm) RemoveAll() error {
+	var errs []error
+	for _, files := range f.File {
+		for _, fh := range files {
+			if fh.tmpfile == "" {
+				continue
+			}
+			if err := os.Remove(fh.tmpfile); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
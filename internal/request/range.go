@@ -0,0 +1,96 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by ParseRange
+var (
+	ErrInvalidRange = errors.New("invalid range")
+	ErrNoOverlap    = errors.New("range not satisfiable")
+)
+
+// ByteRange is a resolved byte range within a representation of known size
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+// ContentRange formats the range as a Content-Range value for a representation of size bytes
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// ParseRange parses a Range header value (RFC 9110 Section 14.2) against a representation of
+// size bytes. Single, multiple, open-ended ("500-") and suffix ("-500") ranges are supported.
+// Ranges starting past the end are dropped, and ErrNoOverlap is returned if none remain.
+// An empty header returns no ranges and no error
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	unit, specs, ok := strings.Cut(header, "=")
+	if !ok || strings.TrimSpace(unit) != "bytes" {
+		return nil, fmt.Errorf("%w: %s - only byte ranges are supported", ErrInvalidRange, header)
+	}
+
+	var ranges []ByteRange
+	noOverlap := false
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRange, spec)
+		}
+
+		var r ByteRange
+		if first == "" {
+			// Suffix range: the final N bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidRange, spec)
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			n = min(n, size)
+			r = ByteRange{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidRange, spec)
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("%w: %s", ErrInvalidRange, spec)
+				}
+				end = min(end, size-1)
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			r = ByteRange{Start: start, Length: end - start + 1}
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, ErrNoOverlap
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRange, header)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,52 @@
+package request
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	// Test: No header
+	ranges, err := ParseRange("", 100)
+	require.NoError(t, err)
+	assert.Nil(t, ranges)
+
+	// Test: Single closed range
+	ranges, err = ParseRange("bytes=0-49", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 0, Length: 50}}, ranges)
+	assert.Equal(t, "bytes 0-49/100", ranges[0].ContentRange(100))
+
+	// Test: Open-ended, suffix and multiple ranges with whitespace
+	ranges, err = ParseRange("bytes=90-, -5 , 10-19", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 90, Length: 10}, {Start: 95, Length: 5}, {Start: 10, Length: 10}}, ranges)
+
+	// Test: Last position past the end is clamped
+	ranges, err = ParseRange("bytes=50-1000", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 50, Length: 50}}, ranges)
+
+	// Test: Suffix longer than the representation covers all of it
+	ranges, err = ParseRange("bytes=-500", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 0, Length: 100}}, ranges)
+
+	// Test: Unsatisfiable ranges are dropped
+	ranges, err = ParseRange("bytes=200-300, 0-0", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []ByteRange{{Start: 0, Length: 1}}, ranges)
+
+	// Test: Nothing satisfiable
+	_, err = ParseRange("bytes=100-", 100)
+	assert.ErrorIs(t, err, ErrNoOverlap)
+	_, err = ParseRange("bytes=-0", 100)
+	assert.ErrorIs(t, err, ErrNoOverlap)
+
+	// Test: Malformed ranges
+	for _, header := range []string{"items=0-1", "bytes=5-1", "bytes=a-b", "bytes=1", "bytes=", "bytes=--1"} {
+		_, err = ParseRange(header, 100)
+		assert.ErrorIs(t, err, ErrInvalidRange, header)
+	}
+}
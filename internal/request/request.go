@@ -1,8 +1,13 @@
 package request
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/response"
 	"io"
 	"strconv"
 	"strings"
@@ -14,15 +19,36 @@ const (
 	requestStateParsingLine = iota
 	requestStateParsingHeaders
 	requestStateParsingBody
+	requestStateParsingChunked // The body is decoded by RequestFromReader
 	requestStateDone
 )
 
+// Errors returned by RequestFromReader, wrapped with details of the offending input
+var (
+	ErrIncompleteRequest       = errors.New("incomplete request")
+	ErrInvalidRequestLine      = errors.New("invalid request line")
+	ErrInvalidMethod           = errors.New("invalid method")
+	ErrInvalidVersion          = errors.New("invalid http version")
+	ErrInvalidContentLength    = errors.New("invalid content length")
+	ErrInvalidTransferEncoding = errors.New("invalid transfer encoding")
+	ErrBodyTooLarge            = errors.New("body too large")
+)
+
+// MaxChunkedBodyBytes caps a decoded chunked body, which unlike Content-Length gives no size up front
+const MaxChunkedBodyBytes = 10 << 20
+
 // Request defines data structure for an incoming request
 type Request struct {
 	RequestLine RequestLine
 	Headers     headers.Headers
 	state       int
 	Body        []byte
+	RemoteAddr  string // Set by the server to the peer's network address
+	// EncodedBodyLength is the size of the body as received, set when a
+	// Content-Encoding was decoded so Body holds the decoded bytes
+	EncodedBodyLength int
+	ctx               context.Context
+	buffered          []byte
 }
 
 // RequestLine defines data structure for the start-line (RFC 9110)
@@ -32,6 +58,32 @@ type RequestLine struct {
 	Method        string
 }
 
+// Context returns the request's context, which is never nil.
+// For server requests it is cancelled when the client disconnects, the server
+// shuts down or the server's WriteTimeout elapses.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// Buffered returns any bytes read from the reader beyond the end of the request
+func (r *Request) Buffered() []byte {
+	return r.buffered
+}
+
+// WithContext returns a shallow copy of the request with its context changed to ctx
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
+}
+
 // RequestFromReader creates a new Request from a reader input
 func RequestFromReader(r io.Reader) (*Request, error) {
 	// Initialize request
@@ -72,16 +124,26 @@ func RequestFromReader(r io.Reader) (*Request, error) {
 		leftover = data[bytesProcessed:]
 
 		// If parsing is complete, break out of the loop
-		if req.state == requestStateDone {
+		if req.state == requestStateDone || req.state == requestStateParsingChunked {
 			break
 		}
 	}
 
+	if req.state == requestStateParsingChunked {
+		var err error
+		if leftover, err = req.readChunked(io.MultiReader(bytes.NewReader(leftover), r)); err != nil {
+			return nil, err
+		}
+	}
+
 	// If not in the done state, request must not have been complete
 	if req.state != requestStateDone {
-		return nil, fmt.Errorf("incomplete request")
+		return nil, ErrIncompleteRequest
 	}
 
+	// Keep anything read past the end of the request, e.g. for protocol upgrades
+	req.buffered = bytes.Clone(leftover)
+
 	if req.state == requestStateParsingBody {
 		contentLengthStr := string(req.Headers.Get("Content-Length"))
 		if contentLengthStr != "" {
@@ -90,7 +152,7 @@ func RequestFromReader(r io.Reader) (*Request, error) {
 				return nil, err
 			}
 			if len(req.Body) < contentLength {
-				return nil, fmt.Errorf("body too short")
+				return nil, fmt.Errorf("%w: body too short", ErrIncompleteRequest)
 			}
 		}
 	}
@@ -98,6 +160,26 @@ func RequestFromReader(r io.Reader) (*Request, error) {
 	return req, nil
 }
 
+// readChunked decodes a chunked body from src, returning what was read past its end.
+// Bodies over MaxChunkedBodyBytes fail with ErrBodyTooLarge
+func (r *Request) readChunked(src io.Reader) ([]byte, error) {
+	br := bufio.NewReader(src)
+	body, err := io.ReadAll(io.LimitReader(response.NewChunkedReader(br), MaxChunkedBodyBytes+1))
+	switch {
+	case err == nil && len(body) > MaxChunkedBodyBytes:
+		return nil, fmt.Errorf("%w: chunked body over %d bytes", ErrBodyTooLarge, MaxChunkedBodyBytes)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, fmt.Errorf("%w: body too short", ErrIncompleteRequest)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTransferEncoding, err)
+	}
+
+	r.Body = body
+	r.state = requestStateDone
+	rest, _ := br.Peek(br.Buffered())
+	return rest, nil
+}
+
 func (r *Request) parse(data []byte) (int, error) {
 	totalBytesParsed := 0
 
@@ -135,7 +217,7 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 
 		// Check if request line is formatted correctly
 		if len(parts) != 3 {
-			return 0, fmt.Errorf("invalid request line: %s", stringData)
+			return 0, fmt.Errorf("%w: %s", ErrInvalidRequestLine, stringData)
 		}
 
 		// Grab relevant parts of request line
@@ -146,18 +228,18 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		// Check that method is formatted correctly
 		for _, char := range method {
 			if !unicode.IsUpper(char) || !unicode.IsLetter(char) {
-				return 0, fmt.Errorf("invalid method: %s - must only contain uppercase letters", method)
+				return 0, fmt.Errorf("%w: %s - must only contain uppercase letters", ErrInvalidMethod, method)
 			}
 		}
 
 		// Check HTTP version is 1.1
 		if !strings.HasPrefix(httpVersion, "HTTP/") {
-			return 0, fmt.Errorf("invalid http version: %s - must be HTTP/1.1", httpVersion)
+			return 0, fmt.Errorf("%w: %s - must be HTTP/1.1", ErrInvalidVersion, httpVersion)
 		}
 
 		versionNumber := strings.TrimPrefix(httpVersion, "HTTP/")
 		if versionNumber != "1.1" {
-			return 0, fmt.Errorf("invalid http version: %s - must be HTTP/1.1", httpVersion)
+			return 0, fmt.Errorf("%w: %s - must be HTTP/1.1", ErrInvalidVersion, httpVersion)
 		}
 
 		// Set the request line parts to the RequestLine object
@@ -184,6 +266,16 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		return n, nil
 
 	case requestStateParsingBody:
+		// Transfer-Encoding overrides Content-Length and must end in chunked (RFC 9112 Section 6.3)
+		if te := r.Headers.Get("Transfer-Encoding"); te != "" {
+			if !response.IsChunked(te) {
+				return 0, fmt.Errorf("%w: %s", ErrInvalidTransferEncoding, te)
+			}
+			r.Headers.Del("Content-Length")
+			r.state = requestStateParsingChunked
+			return 0, nil
+		}
+
 		// Get Content-Length header
 		contentLengthStr := r.Headers.Get("Content-Length")
 
@@ -196,7 +288,7 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		// Parse Content-Length to int
 		contentLength, err := strconv.Atoi(contentLengthStr)
 		if err != nil {
-			return 0, fmt.Errorf("invalid content length: %s", contentLengthStr)
+			return 0, fmt.Errorf("%w: %s", ErrInvalidContentLength, contentLengthStr)
 		}
 
 		// If Content-Length == 0 -> no body to parse
@@ -1,6 +1,8 @@
 package request
 
 import (
+	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"strings"
@@ -143,4 +145,80 @@ func TestHeaders_Parse_Body(t *testing.T) {
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
+
+	// Test: Chunked body is decoded, overriding Content-Length
+	reader = &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Content-Length: 3\r\n" +
+			"\r\n" +
+			"6;ext=1\r\nhello \r\n6\r\nworld!\r\n0\r\nX-Trailer: yes\r\n\r\nGET",
+		numBytesPerRead: 3,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!", string(r.Body))
+	assert.Empty(t, r.Headers.Get("Content-Length"))
+
+	// Test: Truncated chunked body
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n6\r\nhel"))
+	require.ErrorIs(t, err, ErrIncompleteRequest)
+
+	// Test: Transfer-Encoding that doesn't end in chunked, or a malformed chunk size
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\nabc"))
+	require.ErrorIs(t, err, ErrInvalidTransferEncoding)
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"))
+	require.ErrorIs(t, err, ErrInvalidTransferEncoding)
+
+	// Test: Chunked body over MaxChunkedBodyBytes, checked before the last chunk arrives
+	chunk := fmt.Sprintf("%x\r\n%s\r\n", MaxChunkedBodyBytes/2+1, strings.Repeat("a", MaxChunkedBodyBytes/2+1))
+	_, err = RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" + chunk + chunk))
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func TestRequestContext(t *testing.T) {
+	// Test: Parsed request has a background context
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, context.Background(), r.Context())
+
+	// Test: WithContext returns a copy with the new context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r2 := r.WithContext(ctx)
+	assert.Equal(t, ctx, r2.Context())
+	assert.Equal(t, context.Background(), r.Context())
+	assert.Equal(t, r.RequestLine, r2.RequestLine)
+}
+
+func TestBuffered(t *testing.T) {
+	// Test: Bytes past the end of the request are kept
+	reader := &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: custom\r\n\r\nextra bytes",
+		numBytesPerRead: 100,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "extra bytes", string(r.Buffered()))
+
+	// Test: Bytes past the body are kept
+	reader = &chunkReader{
+		data:            "POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhelloGET",
+		numBytesPerRead: 100,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+	assert.Equal(t, "GET", string(r.Buffered()))
+
+	// Test: Bytes past a chunked body are kept
+	reader = &chunkReader{
+		data:            "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\nGET",
+		numBytesPerRead: 100,
+	}
+	r, err = RequestFromReader(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+	assert.Equal(t, "GET", string(r.Buffered()))
 }
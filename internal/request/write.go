@@ -0,0 +1,115 @@
+package request
+
+import (
+	"bufio"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WriteTo writes the request as an HTTP/1.1 message (RFC 9112). The fields are canonicalised
+// rather than kept in the order received: Host comes first and the others follow sorted by
+// name, with the key casing held in Headers, which is lowercase for parsed requests. The body
+// is sent chunked when Transfer-Encoding ends in chunked, otherwise with a Content-Length,
+// which is left out for an empty body unless the method expects content or one was set.
+// An invalid request line or field is reported before anything is written to w
+func (r *Request) WriteTo(w io.Writer) (int64, error) {
+	method, target := r.RequestLine.Method, r.RequestLine.RequestTarget
+	if method == "" || target == "" || strings.ContainsAny(method+target, " \r\n") {
+		return 0, fmt.Errorf("%w: %q %q", ErrInvalidRequestLine, method, target)
+	}
+	version := r.RequestLine.HttpVersion
+	if version == "" {
+		version = "1.1"
+	}
+
+	// Re-frame the body, dropping any framing fields that no longer match
+	h := headers.NewHeaders()
+	for k, v := range r.Headers {
+		h[k] = v
+	}
+	chunked := false
+	if te := h.Get("Transfer-Encoding"); te != "" {
+		codings := strings.Split(te, ",")
+		chunked = strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+		if !chunked {
+			h.Del("Transfer-Encoding")
+		}
+	}
+	lengthKey, hadLength := "Content-Length", false
+	for k := range h {
+		if strings.EqualFold(k, "Content-Length") {
+			lengthKey, hadLength = k, true
+			delete(h, k)
+		}
+	}
+	if !chunked && (len(r.Body) > 0 || hadLength || method == "POST" || method == "PUT" || method == "PATCH") {
+		h[lengthKey] = strconv.Itoa(len(r.Body))
+	}
+
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		if strings.ContainsAny(k, ": \t\r\n") {
+			return 0, fmt.Errorf("%w: %q", headers.ErrInvalidKey, k)
+		}
+		for _, v := range h.Values(k) {
+			if strings.ContainsAny(v, "\r\n") {
+				return 0, fmt.Errorf("%w: %s - line break in value", headers.ErrInvalidFormat, k)
+			}
+		}
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		// Host first (RFC 9112 Section 3.2), then by name regardless of case
+		aHost, bHost := strings.EqualFold(a, "Host"), strings.EqualFold(b, "Host")
+		switch {
+		case aHost && !bHost:
+			return -1
+		case bHost && !aHost:
+			return 1
+		}
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	fmt.Fprintf(bw, "%s %s HTTP/%s\r\n", method, target, version)
+	for _, k := range keys {
+		// Set-Cookie style values hold one field line each
+		values := h.Values(k)
+		if values == nil {
+			values = []string{""}
+		}
+		for _, v := range values {
+			fmt.Fprintf(bw, "%s: %s\r\n", k, v)
+		}
+	}
+	bw.WriteString("\r\n")
+
+	if chunked {
+		if len(r.Body) > 0 {
+			fmt.Fprintf(bw, "%x\r\n%s\r\n", len(r.Body), r.Body)
+		}
+		bw.WriteString("0\r\n\r\n")
+	} else {
+		bw.Write(r.Body)
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,147 @@
+package request
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/headers"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	// Test: Parsed requests are canonicalised, Host first, fields sorted and keys lowercase
+	raw := "POST /submit HTTP/1.1\r\nUser-Agent: curl/7.81.0\r\nHost: localhost:42069\r\nAccept: */*\r\nContent-Length: 5\r\n\r\nhello"
+	r, err := RequestFromReader(&chunkReader{data: raw, numBytesPerRead: 3})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /submit HTTP/1.1\r\nhost: localhost:42069\r\naccept: */*\r\ncontent-length: 5\r\nuser-agent: curl/7.81.0\r\n\r\nhello", buf.String())
+	assert.EqualValues(t, buf.Len(), n)
+
+	// Test: Stored casing is kept and a stale Content-Length is replaced
+	r = &Request{
+		RequestLine: RequestLine{Method: "PUT", RequestTarget: "/item"},
+		Headers:     headers.Headers{"Host": "example.com", "X-Trace-ID": "abc", "Content-Length": "99"},
+		Body:        []byte("data"),
+	}
+	buf.Reset()
+	_, err = r.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "PUT /item HTTP/1.1\r\nHost: example.com\r\nContent-Length: 4\r\nX-Trace-ID: abc\r\n\r\ndata", buf.String())
+
+	// Test: GET without a body has no Content-Length
+	r = &Request{RequestLine: RequestLine{Method: "GET", RequestTarget: "/"}, Headers: headers.Headers{"Host": "a"}}
+	buf.Reset()
+	_, err = r.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: a\r\n\r\n", buf.String())
+
+	// Test: Chunked requests are re-framed as a single chunk
+	r = &Request{
+		RequestLine: RequestLine{Method: "POST", RequestTarget: "/upload"},
+		Headers:     headers.Headers{"Host": "a", "Transfer-Encoding": "chunked", "Content-Length": "3"},
+		Body:        []byte("hello world"),
+	}
+	buf.Reset()
+	_, err = r.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /upload HTTP/1.1\r\nHost: a\r\nTransfer-Encoding: chunked\r\n\r\nb\r\nhello world\r\n0\r\n\r\n", buf.String())
+
+	// Test: Line breaks cannot be smuggled into the message, and nothing is written
+	r = &Request{
+		RequestLine: RequestLine{Method: "GET", RequestTarget: "/"},
+		Headers:     headers.Headers{"Host": "a", "Accept": strings.Repeat("x", 8192), "X-Bad": "a\r\nInjected: 1"},
+	}
+	buf.Reset()
+	n, err = r.WriteTo(&buf)
+	assert.ErrorIs(t, err, headers.ErrInvalidFormat)
+	assert.Zero(t, n)
+	assert.Zero(t, buf.Len())
+	r = &Request{RequestLine: RequestLine{Method: "GET", RequestTarget: "/ HTTP/1.1\r\n"}}
+	_, err = r.WriteTo(&buf)
+	assert.ErrorIs(t, err, ErrInvalidRequestLine)
+}
+
+// randomRequest generates a request the parser can represent exactly
+func randomRequest(rng *rand.Rand) *Request {
+	const (
+		upper     = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		tchars    = "!#$%&'*+-.^_`|~0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		pathChars = "abcdefghijklmnopqrstuvwxyz0123456789-._~/?=&%"
+	)
+	pick := func(chars string, min, max int) string {
+		var b strings.Builder
+		for range min + rng.IntN(max-min+1) {
+			b.WriteByte(chars[rng.IntN(len(chars))])
+		}
+		return b.String()
+	}
+
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", pick(upper, 1, 10)}
+	r := &Request{
+		RequestLine: RequestLine{
+			HttpVersion:   "1.1",
+			Method:        methods[rng.IntN(len(methods))],
+			RequestTarget: "/" + pick(pathChars, 0, 40),
+		},
+		Headers: headers.NewHeaders(),
+	}
+	for range rng.IntN(10) {
+		key := pick(tchars, 1, 20)
+		if strings.EqualFold(key, "Content-Length") || strings.EqualFold(key, "Transfer-Encoding") || r.Headers.Get(key) != "" {
+			continue
+		}
+		// Visible characters with optional inner spaces, as the parser trims the ends
+		value := pick("abc XYZ 019,;=\"!~", 0, 30)
+		r.Headers.Set(key, strings.TrimSpace(value))
+	}
+	if rng.IntN(3) > 0 {
+		body := make([]byte, rng.IntN(3000))
+		for i := range body {
+			body[i] = byte(rng.IntN(256))
+		}
+		if len(body) > 0 {
+			r.Body = body
+		}
+	}
+	return r
+}
+
+func TestWriteTo_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range 500 {
+		want := randomRequest(rng)
+		var buf bytes.Buffer
+		_, err := want.WriteTo(&buf)
+		require.NoError(t, err)
+
+		// Test: parse(write(req)) == req, with keys lowercased by the parser and the
+		// Content-Length framing WriteTo adds
+		got, err := RequestFromReader(&chunkReader{data: buf.String(), numBytesPerRead: 1 + rng.IntN(64)})
+		require.NoError(t, err, "case %d: %q", i, buf.String())
+
+		wantHeaders := headers.NewHeaders()
+		for k, v := range want.Headers {
+			wantHeaders[strings.ToLower(k)] = v
+		}
+		if method := want.RequestLine.Method; len(want.Body) > 0 || method == "POST" || method == "PUT" || method == "PATCH" {
+			wantHeaders["content-length"] = strconv.Itoa(len(want.Body))
+		}
+		assert.Equal(t, want.RequestLine, got.RequestLine, "case %d", i)
+		assert.Equal(t, wantHeaders, got.Headers, "case %d", i)
+		assert.Equal(t, want.Body, got.Body, "case %d", i)
+
+		// Test: Writing the parsed request again gives the same message, up to key casing
+		var again bytes.Buffer
+		_, err = got.WriteTo(&again)
+		require.NoError(t, err)
+		head, body, _ := strings.Cut(buf.String(), "\r\n\r\n")
+		againHead, againBody, _ := strings.Cut(again.String(), "\r\n\r\n")
+		assert.Equal(t, strings.ToLower(head), strings.ToLower(againHead), "case %d", i)
+		assert.Equal(t, body, againBody, "case %d", i)
+	}
+}
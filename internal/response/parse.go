@@ -0,0 +1,278 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+)
+
+// Define possible states
+const (
+	responseStateParsingLine = iota
+	responseStateParsingHeaders
+	responseStateParsingBody
+	responseStateParsingLength
+	responseStateParsingChunkSize
+	responseStateParsingChunkData
+	responseStateParsingChunkEnd
+	responseStateParsingTrailers
+	responseStateParsingUntilClose
+	responseStateDone
+)
+
+// ErrIncompleteResponse is returned by ResponseFromReader when the input ends early
+var ErrIncompleteResponse = errors.New("incomplete response")
+
+// Response defines data structure for a response read back from a connection
+type Response struct {
+	StatusLine StatusLine
+	Headers    headers.Headers
+	Body       []byte // Decoded body, chunked framing removed
+	state      int
+	method     string
+	remaining  int64
+	headBytes  int // Size of the status line and headers parsed so far
+	buffered   []byte
+}
+
+// Buffered returns any bytes read from the reader beyond the end of the response
+func (r *Response) Buffered() []byte {
+	return r.buffered
+}
+
+// ResponseFromReader parses a response to a request made with method, framing the
+// body per RFC 9112 Section 6.3 with the same rules as NewBodyReader: none after HEAD or
+// for 1xx, 204 and 304, chunked when that is the final transfer coding, then
+// Content-Length, and otherwise until EOF. Like ReadHead it requires CRLF line endings
+// and limits the head to MaxHeadBytes. An interim 1xx response is returned as is, read
+// again for the final one
+func ResponseFromReader(r io.Reader, method string) (*Response, error) {
+	// Initialize response
+	resp := &Response{
+		Headers: headers.NewHeaders(),
+		state:   responseStateParsingLine,
+		method:  method,
+	}
+
+	buf := make([]byte, 1024)
+	var leftover []byte
+	for {
+		// Read data in chunks from the reader
+		n, err := r.Read(buf)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+
+		// Combine leftover data with newly read data and parse as much as possible
+		data := append(leftover, buf[:n]...)
+		bytesProcessed, err := resp.parse(data)
+		if err != nil {
+			return nil, err
+		}
+		leftover = data[bytesProcessed:]
+		// A line still being received counts towards the head limit too
+		if resp.inHead() && resp.headBytes+len(leftover) > MaxHeadBytes {
+			return nil, ErrHeadTooLarge
+		}
+
+		if resp.state == responseStateDone {
+			break
+		}
+	}
+
+	// A body without framing ends with the connection
+	if resp.state == responseStateParsingUntilClose {
+		resp.state = responseStateDone
+	}
+	if resp.state != responseStateDone {
+		return nil, ErrIncompleteResponse
+	}
+
+	resp.buffered = bytes.Clone(leftover)
+	return resp, nil
+}
+
+func (r *Response) parse(data []byte) (int, error) {
+	totalBytesParsed := 0
+	for r.state != responseStateDone {
+		state := r.state
+		n, err := r.parseSingle(data[totalBytesParsed:])
+		if err != nil {
+			return totalBytesParsed, err
+		}
+
+		// Stop when more data is needed, but carry on after a state change that consumed nothing
+		if n == 0 && r.state == state {
+			break
+		}
+		totalBytesParsed += n
+	}
+	return totalBytesParsed, nil
+}
+
+func (r *Response) parseSingle(data []byte) (int, error) {
+	switch r.state {
+	case responseStateParsingLine:
+		line, n, err := r.cutHeadLine(data)
+		if n == 0 {
+			return 0, err
+		}
+		sl, err := ParseStatusLine(line)
+		if err != nil {
+			return 0, err
+		}
+		r.StatusLine = sl
+		r.state = responseStateParsingHeaders
+		return n, nil
+
+	// One line at a time, so a bare LF can't hide a field inside the next line
+	case responseStateParsingHeaders:
+		line, n, err := r.cutHeadLine(data)
+		if errors.Is(err, ErrMissingCRLF) {
+			return 0, fmt.Errorf("%w: %w", headers.ErrInvalidFormat, err)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		_, done, err := r.Headers.Parse([]byte(line + "\r\n"))
+		if err != nil {
+			return 0, err
+		}
+		if done {
+			r.state = responseStateParsingBody
+		}
+		return n, nil
+
+	// Pick the body framing once the headers are known
+	case responseStateParsingBody:
+		if !HasBody(r.method, r.StatusLine.StatusCode) {
+			r.state = responseStateDone
+			return 0, nil
+		}
+		if te := r.Headers.Get("Transfer-Encoding"); te != "" {
+			if IsChunked(te) {
+				r.state = responseStateParsingChunkSize
+			} else {
+				r.state = responseStateParsingUntilClose
+			}
+			return 0, nil
+		}
+		contentLength, err := ContentLength(r.Headers)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case contentLength < 0:
+			r.state = responseStateParsingUntilClose
+		case contentLength == 0:
+			r.state = responseStateDone
+		default:
+			r.remaining = contentLength
+			r.state = responseStateParsingLength
+		}
+		return 0, nil
+
+	case responseStateParsingLength, responseStateParsingChunkData:
+		n := r.appendBody(data)
+		if r.remaining == 0 {
+			if r.state == responseStateParsingLength {
+				r.state = responseStateDone
+			} else {
+				r.state = responseStateParsingChunkEnd
+			}
+		}
+		return n, nil
+
+	case responseStateParsingChunkSize:
+		line, n, err := cutLine(data)
+		if n == 0 {
+			return 0, err
+		}
+		size, err := parseChunkSize(line)
+		if err != nil {
+			return 0, err
+		}
+		if size == 0 {
+			r.state = responseStateParsingTrailers
+		} else {
+			r.remaining = size
+			r.state = responseStateParsingChunkData
+		}
+		return n, nil
+
+	case responseStateParsingChunkEnd:
+		if len(data) < 2 {
+			return 0, nil
+		}
+		if string(data[:2]) != "\r\n" {
+			return 0, fmt.Errorf("%w: missing CRLF after chunk data", ErrInvalidChunk)
+		}
+		r.state = responseStateParsingChunkSize
+		return 2, nil
+
+	// Trailer fields are discarded, an empty line ends the message
+	case responseStateParsingTrailers:
+		line, n, err := cutLine(data)
+		if n == 0 {
+			return 0, err
+		}
+		if line == "" {
+			r.state = responseStateDone
+		}
+		return n, nil
+
+	case responseStateParsingUntilClose:
+		r.Body = append(r.Body, data...)
+		return len(data), nil
+
+	default:
+		break
+	}
+	return 0, nil
+}
+
+// appendBody moves up to r.remaining bytes of data into the body
+func (r *Response) appendBody(data []byte) int {
+	bytesToRead := int64(len(data))
+	if bytesToRead > r.remaining {
+		bytesToRead = r.remaining
+	}
+	r.Body = append(r.Body, data[:bytesToRead]...)
+	r.remaining -= bytesToRead
+	return int(bytesToRead)
+}
+
+// inHead reports whether the status line or headers are still being parsed
+func (r *Response) inHead() bool {
+	return r.state == responseStateParsingLine || r.state == responseStateParsingHeaders
+}
+
+// cutHeadLine cuts a line of the status line or headers, keeping them within MaxHeadBytes
+func (r *Response) cutHeadLine(data []byte) (string, int, error) {
+	line, n, err := cutLine(data)
+	if r.headBytes += n; r.headBytes > MaxHeadBytes {
+		return "", 0, ErrHeadTooLarge
+	}
+	return line, n, err
+}
+
+// cutLine returns the first CRLF terminated line without its ending and the bytes it
+// spans, or 0 when data holds no complete line yet. A line ending in a bare LF is an error
+func cutLine(data []byte) (string, int, error) {
+	endOfLine := bytes.IndexByte(data, '\n')
+	if endOfLine == -1 {
+		return "", 0, nil
+	}
+	if endOfLine == 0 || data[endOfLine-1] != '\r' {
+		return "", 0, fmt.Errorf("%w: %q", ErrMissingCRLF, data[:endOfLine+1])
+	}
+	return string(data[:endOfLine-1]), endOfLine + 1, nil
+}
@@ -0,0 +1,138 @@
+package response
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+	"testing"
+)
+
+type chunkReader struct {
+	data            string
+	numBytesPerRead int
+	pos             int
+}
+
+// Read reads up to len(p) or numBytesPerRead bytes from the string per call,
+// simulating a network connection that delivers data in pieces
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	if cr.pos >= len(cr.data) {
+		return 0, io.EOF
+	}
+	endIndex := cr.pos + cr.numBytesPerRead
+	if endIndex > len(cr.data) {
+		endIndex = len(cr.data)
+	}
+	n = copy(p, cr.data[cr.pos:endIndex])
+	cr.pos += n
+	return n, nil
+}
+
+func TestResponseFromReader(t *testing.T) {
+	// Test: Status line, headers and Content-Length body, one byte at a time
+	reader := &chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 13\r\n\r\nhello world!\n",
+		numBytesPerRead: 1,
+	}
+	r, err := ResponseFromReader(reader, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, StatusLine{HttpVersion: "1.1", StatusCode: 200, ReasonPhrase: "OK"}, r.StatusLine)
+	assert.Equal(t, "text/plain", r.Headers.Get("Content-Type"))
+	assert.Equal(t, "hello world!\n", string(r.Body))
+
+	// Test: Multi-word reason phrase and bytes past the end are kept
+	reader = &chunkReader{
+		data:            "HTTP/1.1 404 Not Found\r\nContent-Length: 4\r\n\r\nnopeHTTP/1.1",
+		numBytesPerRead: 1024,
+	}
+	r, err = ResponseFromReader(reader, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "Not Found", r.StatusLine.ReasonPhrase)
+	assert.Equal(t, "nope", string(r.Body))
+	assert.Equal(t, "HTTP/1.1", string(r.Buffered()))
+
+	// Test: Chunked body with extensions and trailers
+	reader = &chunkReader{
+		data: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"6;ext=1\r\nfirst \r\n" +
+			"6\r\nsecond\r\n" +
+			"0\r\nX-Checksum: abc\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err = ResponseFromReader(reader, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(r.Body))
+
+	// Test: Without framing the body runs until EOF
+	reader = &chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nConnection: close\r\n\r\nuntil the end",
+		numBytesPerRead: 5,
+	}
+	r, err = ResponseFromReader(reader, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "until the end", string(r.Body))
+
+	// Test: HEAD, 1xx, 204 and 304 have no body regardless of Content-Length
+	for _, tc := range []struct{ method, raw string }{
+		{"HEAD", "HTTP/1.1 200 OK\r\nContent-Length: 20\r\n\r\n"},
+		{"GET", "HTTP/1.1 100 Continue\r\n\r\n"},
+		{"GET", "HTTP/1.1 204 No Content\r\n\r\n"},
+		{"GET", "HTTP/1.1 304 Not Modified\r\nContent-Length: 20\r\n\r\n"},
+	} {
+		reader = &chunkReader{data: tc.raw, numBytesPerRead: 2}
+		r, err = ResponseFromReader(reader, tc.method)
+		require.NoError(t, err, tc.raw)
+		assert.Empty(t, r.Body, tc.raw)
+	}
+
+	// Test: Body shorter than reported content length
+	reader = &chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 20\r\n\r\npartial content",
+		numBytesPerRead: 3,
+	}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrIncompleteResponse)
+
+	// Test: Chunked body cut off before the last chunk
+	reader = &chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrIncompleteResponse)
+
+	// Test: Malformed input
+	reader = &chunkReader{data: "HTTP/1.1 OK\r\n\r\n", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrInvalidStatusLine)
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nContent-Length: 1, 2\r\n\r\n", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrInvalidContentLength)
+
+	// Test: Lines ending in a bare LF are rejected, in the head and in chunk framing
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nContent-Length: 5\nX-Other: 1\r\n\r\nhello", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, headers.ErrInvalidFormat)
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\n\r\n", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrMissingCRLF)
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\nhello\r\n0\r\n\r\n", numBytesPerRead: 3}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrMissingCRLF)
+
+	// Test: Long header lines are read in small pieces, up to MaxHeadBytes
+	long := strings.Repeat("x", 10000)
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nX-Long: " + long + "\r\nContent-Length: 2\r\n\r\nok", numBytesPerRead: 7}
+	r, err = ResponseFromReader(reader, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, long, r.Headers.Get("X-Long"))
+	assert.Equal(t, "ok", string(r.Body))
+	reader = &chunkReader{data: "HTTP/1.1 200 OK\r\nX-Long: " + strings.Repeat("x", MaxHeadBytes) + "\r\n\r\n", numBytesPerRead: 4096}
+	_, err = ResponseFromReader(reader, "GET")
+	assert.ErrorIs(t, err, ErrHeadTooLarge)
+}
@@ -0,0 +1,295 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxHeadBytes limits the size of a status line and header block read by ReadHead
+const MaxHeadBytes = 1 << 20
+
+// Errors returned when reading a response, wrapped with details of the offending input
+var (
+	ErrInvalidStatusLine    = errors.New("invalid status line")
+	ErrInvalidContentLength = errors.New("invalid content length")
+	ErrInvalidChunk         = errors.New("invalid chunk")
+	ErrHeadTooLarge         = errors.New("response head too large")
+	ErrMissingCRLF          = errors.New("line not terminated by CRLF")
+)
+
+// StatusLine is the start-line of a response (RFC 9112 Section 4)
+type StatusLine struct {
+	HttpVersion  string
+	StatusCode   StatusCode
+	ReasonPhrase string
+}
+
+// ParseStatusLine parses "HTTP/1.1 200 OK", the reason phrase may be empty
+func ParseStatusLine(line string) (StatusLine, error) {
+	version, rest, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(version, "HTTP/1.") || len(version) != len("HTTP/1.1") {
+		return StatusLine{}, fmt.Errorf("%w: %q", ErrInvalidStatusLine, line)
+	}
+	code, reason, _ := strings.Cut(rest, " ")
+	status, err := strconv.Atoi(code)
+	if err != nil || len(code) != 3 || status < 100 {
+		return StatusLine{}, fmt.Errorf("%w: %q - bad status code", ErrInvalidStatusLine, line)
+	}
+	return StatusLine{
+		HttpVersion:  strings.TrimPrefix(version, "HTTP/"),
+		StatusCode:   StatusCode(status),
+		ReasonPhrase: reason,
+	}, nil
+}
+
+// ReadHead reads a status line and header block from br, leaving br at the start of the body.
+// Every line must end in CRLF, and the whole head must fit in MaxHeadBytes
+func ReadHead(br *bufio.Reader) (StatusLine, headers.Headers, error) {
+	line, err := readLine(br, MaxHeadBytes)
+	if err != nil {
+		return StatusLine{}, nil, err
+	}
+	sl, err := ParseStatusLine(line)
+	if err != nil {
+		return StatusLine{}, nil, err
+	}
+
+	h := headers.NewHeaders()
+	size := len(line) + 2
+	for {
+		line, err := readLine(br, MaxHeadBytes-size)
+		if errors.Is(err, ErrMissingCRLF) {
+			err = fmt.Errorf("%w: %w", headers.ErrInvalidFormat, err)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return StatusLine{}, nil, err
+		}
+		size += len(line) + 2
+		_, done, err := h.Parse([]byte(line + "\r\n"))
+		if err != nil {
+			return StatusLine{}, nil, err
+		}
+		if done {
+			return sl, h, nil
+		}
+	}
+}
+
+// readLine reads a CRLF terminated line of at most limit bytes, returning it without the line ending
+func readLine(br *bufio.Reader, limit int) (string, error) {
+	var line []byte
+	for {
+		fragment, err := br.ReadSlice('\n')
+		if len(line)+len(fragment) > limit {
+			return "", ErrHeadTooLarge
+		}
+		line = append(line, fragment...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		break
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", fmt.Errorf("%w: %q", ErrMissingCRLF, line)
+	}
+	return string(line[:len(line)-2]), nil
+}
+
+// HasBody reports whether a response to a request with the given method carries
+// a body (RFC 9112 Section 6.3 rules 1 and 2)
+func HasBody(method string, statusCode StatusCode) bool {
+	if method == "HEAD" || !BodyAllowed(statusCode) {
+		return false
+	}
+	return !(method == "CONNECT" && statusCode >= 200 && statusCode < 300)
+}
+
+// ContentLength returns the framing length given by the headers, -1 when the body is
+// chunked or delimited by the connection closing
+func ContentLength(h headers.Headers) (int64, error) {
+	if te := h.Get("Transfer-Encoding"); te != "" {
+		return -1, nil
+	}
+	value := h.Get("Content-Length")
+	if value == "" {
+		return -1, nil
+	}
+	// Repeated identical values are merged with commas and still valid
+	first, _, _ := strings.Cut(value, ",")
+	for _, v := range strings.Split(value, ",") {
+		if strings.TrimSpace(v) != strings.TrimSpace(first) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidContentLength, value)
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidContentLength, value)
+	}
+	return n, nil
+}
+
+// NewBodyReader returns a reader for the body of a response read with ReadHead, framed
+// per RFC 9112 Section 6.3: no body for HEAD, 1xx, 204 and 304, chunked if that is the
+// final transfer coding, then Content-Length, and otherwise until the connection closes
+func NewBodyReader(br *bufio.Reader, method string, statusCode StatusCode, h headers.Headers) (io.Reader, error) {
+	if !HasBody(method, statusCode) {
+		return strings.NewReader(""), nil
+	}
+
+	if te := h.Get("Transfer-Encoding"); te != "" {
+		if IsChunked(te) {
+			return NewChunkedReader(br), nil
+		}
+		return br, nil
+	}
+
+	n, err := ContentLength(h)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return br, nil
+	}
+	return &lengthReader{r: br, remaining: n}, nil
+}
+
+// IsChunked reports whether chunked is the final coding of a Transfer-Encoding value,
+// which then frames the body (RFC 9112 Section 6.3 rule 4)
+func IsChunked(te string) bool {
+	codings := strings.Split(te, ",")
+	return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+}
+
+// parseChunkSize parses a chunk-size line, ignoring chunk extensions
+func parseChunkSize(line string) (int64, error) {
+	size, _, _ := strings.Cut(line, ";")
+	n, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: size %q", ErrInvalidChunk, line)
+	}
+	return n, nil
+}
+
+// lengthReader reads exactly remaining bytes, reporting a short body as io.ErrUnexpectedEOF
+type lengthReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *lengthReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if errors.Is(err, io.EOF) && l.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if l.remaining == 0 && err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// chunkedReader decodes a chunked body (RFC 9112 Section 7.1), discarding extensions and trailers
+type chunkedReader struct {
+	br        *bufio.Reader
+	remaining int64
+	done      bool
+}
+
+// NewChunkedReader decodes the chunked transfer coding from br
+func NewChunkedReader(br *bufio.Reader) io.Reader {
+	return &chunkedReader{br: br}
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		err = c.expectCRLF()
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextChunk reads a chunk-size line, and the trailer section after the last chunk
+func (c *chunkedReader) nextChunk() error {
+	line, err := readLine(c.br, MaxHeadBytes)
+	if err != nil {
+		return unexpected(err)
+	}
+	n, err := parseChunkSize(line)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		c.remaining = n
+		return nil
+	}
+
+	for {
+		trailer, err := readLine(c.br, MaxHeadBytes)
+		if err != nil {
+			return unexpected(err)
+		}
+		if trailer == "" {
+			c.done = true
+			return nil
+		}
+	}
+}
+
+// expectCRLF consumes the line ending after chunk data
+func (c *chunkedReader) expectCRLF() error {
+	line, err := readLine(c.br, MaxHeadBytes)
+	if err != nil {
+		return unexpected(err)
+	}
+	if line != "" {
+		return fmt.Errorf("%w: missing CRLF after chunk data", ErrInvalidChunk)
+	}
+	return nil
+}
+
+// unexpected turns a clean EOF inside a body into io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
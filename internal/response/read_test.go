@@ -0,0 +1,111 @@
+package response
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusLine(t *testing.T) {
+	sl, err := ParseStatusLine("HTTP/1.1 404 Not Found")
+	require.NoError(t, err)
+	assert.Equal(t, StatusLine{HttpVersion: "1.1", StatusCode: 404, ReasonPhrase: "Not Found"}, sl)
+
+	// Test: Empty reason phrase
+	sl, err = ParseStatusLine("HTTP/1.0 204 ")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCode(204), sl.StatusCode)
+
+	for _, bad := range []string{"HTTP/2 200 OK", "HTTP/1.1 20 OK", "HTTP/1.1 abc OK", "200 OK", ""} {
+		_, err = ParseStatusLine(bad)
+		assert.ErrorIs(t, err, ErrInvalidStatusLine, bad)
+	}
+}
+
+// readBody reads a response head and body from raw
+func readBody(t *testing.T, method, raw string) (StatusLine, string, error) {
+	t.Helper()
+	br := bufio.NewReader(strings.NewReader(raw))
+	sl, h, err := ReadHead(br)
+	require.NoError(t, err)
+	body, err := NewBodyReader(br, method, sl.StatusCode, h)
+	require.NoError(t, err)
+	b, err := io.ReadAll(body)
+	return sl, string(b), err
+}
+
+func TestNewBodyReader(t *testing.T) {
+	// Test: Content-Length stops at the declared length
+	_, body, err := readBody(t, "GET", "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhelloEXTRA")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", body)
+
+	// Test: Chunked with extensions and trailers
+	_, body, err = readBody(t, "GET", "HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip, chunked\r\n\r\n5;ext=1\r\nhello\r\n7\r\n, world\r\n0\r\nExpires: never\r\n\r\nEXTRA")
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world", body)
+
+	// Test: Close-delimited
+	_, body, err = readBody(t, "GET", "HTTP/1.1 200 OK\r\n\r\nuntil the end")
+	require.NoError(t, err)
+	assert.Equal(t, "until the end", body)
+
+	// Test: No body for HEAD, 204 and 304 whatever the headers say
+	for _, raw := range []string{"HTTP/1.1 204 No Content\r\nContent-Length: 5\r\n\r\nhello", "HTTP/1.1 304 Not Modified\r\nContent-Length: 5\r\n\r\nhello"} {
+		_, body, err = readBody(t, "GET", raw)
+		require.NoError(t, err)
+		assert.Empty(t, body)
+	}
+	_, body, err = readBody(t, "HEAD", "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	// Test: Truncated bodies are errors
+	_, _, err = readBody(t, "GET", "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nshort")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, _, err = readBody(t, "GET", "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhel")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, _, err = readBody(t, "GET", "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n")
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+
+	// Test: Conflicting Content-Length values are rejected
+	br := bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\nhello!"))
+	sl, h, err := ReadHead(br)
+	require.NoError(t, err)
+	_, err = NewBodyReader(br, "GET", sl.StatusCode, h)
+	assert.ErrorIs(t, err, ErrInvalidContentLength)
+}
+
+func TestReadHead(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nSet-Cookie: a=1\r\nSet-Cookie: b=2\r\n\r\n"))
+	sl, h, err := ReadHead(br)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", sl.ReasonPhrase)
+	assert.Equal(t, "text/plain", h.Get("Content-Type"))
+	assert.Equal(t, []string{"a=1", "b=2"}, h.Values("Set-Cookie"))
+
+	// Test: Truncated head
+	_, _, err = ReadHead(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Type: text/pl")))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: Lines ending in a bare LF are rejected rather than skipped
+	_, _, err = ReadHead(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Length: 5\n\r\nhello")))
+	assert.ErrorIs(t, err, headers.ErrInvalidFormat)
+	_, _, err = ReadHead(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\nContent-Length: 5\r\n\r\nhello")))
+	assert.ErrorIs(t, err, ErrMissingCRLF)
+	_, body, err := readBody(t, "GET", "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\nhello\r\n0\r\n\r\n")
+	assert.ErrorIs(t, err, ErrMissingCRLF)
+	assert.Empty(t, body)
+
+	// Test: Header lines longer than the bufio buffer are read up to MaxHeadBytes
+	long := strings.Repeat("x", 10000)
+	_, h, err = ReadHead(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nX-Long: " + long + "\r\n\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, long, h.Get("X-Long"))
+	_, _, err = ReadHead(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nX-Long: " + strings.Repeat("x", MaxHeadBytes) + "\r\n\r\n")))
+	assert.ErrorIs(t, err, ErrHeadTooLarge)
+}
@@ -4,66 +4,94 @@ import (
 	"fmt"
 	"httpfromtcp/internal/headers"
 	"io"
-	"net"
 	"strconv"
+	"strings"
 )
 
-type ResponseWriter struct {
-	conn       net.Conn // Holds connection to write to
-	headers    headers.Headers
-	statusCode int
-	body       []byte
-}
-
 type StatusCode int
 
 const (
-	StatusOK            StatusCode = 200
-	StatusBadRequest    StatusCode = 400
-	StatusInternalError StatusCode = 500
+	StatusSwitchingProtocols   StatusCode = 101
+	StatusOK                   StatusCode = 200
+	StatusCreated              StatusCode = 201
+	StatusAccepted             StatusCode = 202
+	StatusNoContent            StatusCode = 204
+	StatusPartialContent       StatusCode = 206
+	StatusMovedPermanently     StatusCode = 301
+	StatusFound                StatusCode = 302
+	StatusSeeOther             StatusCode = 303
+	StatusNotModified          StatusCode = 304
+	StatusTemporaryRedirect    StatusCode = 307
+	StatusPermanentRedirect    StatusCode = 308
+	StatusBadRequest           StatusCode = 400
+	StatusUnauthorized         StatusCode = 401
+	StatusForbidden            StatusCode = 403
+	StatusNotFound             StatusCode = 404
+	StatusMethodNotAllowed     StatusCode = 405
+	StatusNotAcceptable        StatusCode = 406
+	StatusProxyAuthRequired    StatusCode = 407
+	StatusPreconditionFailed   StatusCode = 412
+	StatusContentTooLarge      StatusCode = 413
+	StatusUnsupportedMediaType StatusCode = 415
+	StatusRangeNotSatisfiable  StatusCode = 416
+	StatusUpgradeRequired      StatusCode = 426
+	StatusTooManyRequests      StatusCode = 429
+	StatusInternalError        StatusCode = 500
+	StatusBadGateway           StatusCode = 502
+	StatusServiceUnavailable   StatusCode = 503
+	StatusGatewayTimeout       StatusCode = 504
 )
 
-// SetHeader sets a key-value header pair
-func (rw *ResponseWriter) SetHeader(key, value string) {
-	rw.headers[key] = value
+// statusText maps status codes to their reason phrases (RFC 9110 Section 15)
+var statusText = map[StatusCode]string{
+	StatusSwitchingProtocols:   "Switching Protocols",
+	StatusOK:                   "OK",
+	StatusCreated:              "Created",
+	StatusAccepted:             "Accepted",
+	StatusNoContent:            "No Content",
+	StatusPartialContent:       "Partial Content",
+	StatusMovedPermanently:     "Moved Permanently",
+	StatusFound:                "Found",
+	StatusSeeOther:             "See Other",
+	StatusNotModified:          "Not Modified",
+	StatusTemporaryRedirect:    "Temporary Redirect",
+	StatusPermanentRedirect:    "Permanent Redirect",
+	StatusBadRequest:           "Bad Request",
+	StatusUnauthorized:         "Unauthorized",
+	StatusForbidden:            "Forbidden",
+	StatusNotFound:             "Not Found",
+	StatusMethodNotAllowed:     "Method Not Allowed",
+	StatusNotAcceptable:        "Not Acceptable",
+	StatusProxyAuthRequired:    "Proxy Authentication Required",
+	StatusPreconditionFailed:   "Precondition Failed",
+	StatusContentTooLarge:      "Content Too Large",
+	StatusUnsupportedMediaType: "Unsupported Media Type",
+	StatusRangeNotSatisfiable:  "Range Not Satisfiable",
+	StatusUpgradeRequired:      "Upgrade Required",
+	StatusTooManyRequests:      "Too Many Requests",
+	StatusInternalError:        "Internal Server Error",
+	StatusBadGateway:           "Bad Gateway",
+	StatusServiceUnavailable:   "Service Unavailable",
+	StatusGatewayTimeout:       "Gateway Timeout",
 }
 
-// WriteHeader sets the HTTP status code
-func (rw *ResponseWriter) WriteHeader(statusCode int) {
-	rw.statusCode = statusCode
+// StatusText returns the reason phrase for a status code, or "" if it is unknown
+func StatusText(statusCode StatusCode) string {
+	return statusText[statusCode]
 }
 
-// Write writes the response body
-func (rw *ResponseWriter) Write(body []byte) {
-	rw.body = body
-}
-
-// SendResponse formats the response and sends it over the connection
-func (rw *ResponseWriter) SendResponse() error {
-	response := fmt.Sprintf("HTTP/1.1 %d OK\r\n", rw.statusCode)
-	for k, v := range rw.headers {
-		response += fmt.Sprintf("%s: %s\r\n", k, v)
+// BodyAllowed reports whether a response with the given status may include a body (RFC 9110 Section 6.4.1)
+func BodyAllowed(statusCode StatusCode) bool {
+	if statusCode >= 100 && statusCode < 200 {
+		return false
 	}
-	response += "\r\n" + string(rw.body)
-
-	_, err := rw.conn.Write([]byte(response))
-	return err
+	return statusCode != StatusNoContent && statusCode != StatusNotModified
 }
 
 // WriteStatusLine handles writing the HTTP status of an incoming request
 func WriteStatusLine(w io.Writer, statusCode StatusCode) error {
-	var statusLine string
-
-	switch statusCode {
-	case StatusOK:
-		statusLine = "HTTP/1.1 200 OK\r\n"
-	case StatusBadRequest:
-		statusLine = "HTTP/1.1 400 Bad Request\r\n"
-	case StatusInternalError:
-		statusLine = "HTTP/1.1 500 Internal Server Error\r\n"
-	default:
-		statusLine = fmt.Sprintf("HTTP/1.1 %d \r\n", int(statusCode))
-	}
+	// An unknown status still gets a valid line, the reason phrase is optional
+	statusLine := fmt.Sprintf("HTTP/1.1 %d %s\r\n", int(statusCode), StatusText(statusCode))
 
 	_, err := w.Write([]byte(statusLine))
 	return err
@@ -83,14 +111,19 @@ func GetDefaultHeaders(contentLen int) headers.Headers {
 
 func WriteHeaders(w io.Writer, headers headers.Headers) error {
 	for k, v := range headers {
-		// Format: "Key: Value\r\n"
-		headerLine := fmt.Sprintf("%s: %s\r\n", k, v)
-
-		_, err := w.Write([]byte(headerLine))
-		if err != nil {
-			return err
+		// Format: "Key: Value\r\n", Set-Cookie gets one line per cookie
+		values := []string{v}
+		if strings.EqualFold(k, "Set-Cookie") {
+			values = strings.Split(v, "\n")
 		}
+		for _, value := range values {
+			headerLine := fmt.Sprintf("%s: %s\r\n", k, value)
 
+			_, err := w.Write([]byte(headerLine))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// After all headers, write the final CRLF that separates headers from body
@@ -101,3 +134,27 @@ func WriteHeaders(w io.Writer, headers headers.Headers) error {
 
 	return nil
 }
+
+// WriteChunkedBody writes p as a single chunk (RFC 9112 Section 7.1), empty input writes nothing
+func WriteChunkedBody(w io.Writer, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	_, err := fmt.Fprintf(w, "%x\r\n", len(p))
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	_, err = w.Write([]byte("\r\n"))
+	return n, err
+}
+
+// WriteChunkedBodyDone writes the zero-length chunk and empty trailer that end a chunked body
+func WriteChunkedBodyDone(w io.Writer) error {
+	_, err := w.Write([]byte("0\r\n\r\n"))
+	return err
+}
@@ -0,0 +1,211 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strconv"
+)
+
+// Writer accumulates the response to a single request. The body is buffered so
+// Content-Length can be set, unless Flush is called to stream it with chunked encoding,
+// or as is when the handler declared a Content-Length itself
+type Writer struct {
+	w            io.Writer // Holds connection to write to
+	headers      headers.Headers
+	statusCode   int
+	body         bytes.Buffer
+	streaming    bool
+	fixedLength  bool  // Streaming a body of the declared Content-Length without chunks
+	remaining    int64 // Bytes of a fixed length body still to send
+	done         bool
+	omitBody     bool
+	bytesWritten int
+}
+
+// NewWriter creates a Writer sending its response to w with a 200 status
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:          w,
+		headers:    headers.NewHeaders(),
+		statusCode: int(StatusOK),
+	}
+}
+
+// Header returns the headers that will be sent, merged over GetDefaultHeaders
+func (rw *Writer) Header() headers.Headers {
+	return rw.headers
+}
+
+// SetHeader sets a key-value header pair
+func (rw *Writer) SetHeader(key, value string) {
+	rw.headers.Set(key, value)
+}
+
+// WriteHeader sets the HTTP status code, it has no effect once streaming has started
+func (rw *Writer) WriteHeader(statusCode int) {
+	if rw.streaming {
+		return
+	}
+	rw.statusCode = statusCode
+}
+
+// Write appends to the response body
+func (rw *Writer) Write(p []byte) (int, error) {
+	if rw.done {
+		return 0, fmt.Errorf("response already sent")
+	}
+	return rw.body.Write(p)
+}
+
+// Flush sends the status line and headers if needed, then anything written so far as a chunk,
+// or unframed when the handler set Content-Length before the first Flush. Statuses that can't
+// have a body, such as 204 and 304, get neither framing nor body
+func (rw *Writer) Flush() error {
+	if rw.done {
+		return fmt.Errorf("response already sent")
+	}
+
+	if !rw.streaming {
+		h := rw.mergedHeaders()
+		if length, ok := rw.declaredLength(); ok {
+			rw.fixedLength, rw.remaining = true, length
+			h.Set("Content-Length", strconv.FormatInt(length, 10))
+		} else if BodyAllowed(StatusCode(rw.statusCode)) {
+			h.Del("Content-Length")
+			h.Set("Transfer-Encoding", "chunked")
+		}
+
+		err := WriteStatusLine(rw.w, StatusCode(rw.statusCode))
+		if err != nil {
+			return err
+		}
+		err = WriteHeaders(rw.w, h)
+		if err != nil {
+			return err
+		}
+		rw.streaming = true
+	}
+	// Like SendResponse, no framing for HEAD or a status that can't have a body
+	if rw.omitBody || !BodyAllowed(StatusCode(rw.statusCode)) {
+		rw.body.Reset()
+		return nil
+	}
+	if rw.fixedLength {
+		return rw.flushFixed()
+	}
+
+	n, err := WriteChunkedBody(rw.w, rw.body.Bytes())
+	rw.bytesWritten += n
+	rw.body.Reset()
+	return err
+}
+
+// SendResponse writes whatever has not been sent yet, ending a streamed body if Flush was called
+func (rw *Writer) SendResponse() error {
+	if rw.done {
+		return nil
+	}
+
+	if rw.streaming {
+		err := rw.Flush()
+		rw.done = true
+		if err != nil || rw.omitBody || !BodyAllowed(StatusCode(rw.statusCode)) {
+			return err
+		}
+		if rw.fixedLength {
+			if rw.remaining > 0 {
+				return fmt.Errorf("response body is %d bytes shorter than its Content-Length", rw.remaining)
+			}
+			return nil
+		}
+		return WriteChunkedBodyDone(rw.w)
+	}
+	rw.done = true
+
+	err := WriteStatusLine(rw.w, StatusCode(rw.statusCode))
+	if err != nil {
+		return err
+	}
+	err = WriteHeaders(rw.w, rw.mergedHeaders())
+	if err != nil {
+		return err
+	}
+	if !BodyAllowed(StatusCode(rw.statusCode)) || rw.omitBody {
+		return nil
+	}
+
+	n, err := rw.w.Write(rw.body.Bytes())
+	rw.bytesWritten += n
+	return err
+}
+
+// flushFixed sends the buffered body as is, refusing to exceed the declared length
+func (rw *Writer) flushFixed() error {
+	body := rw.body.Bytes()
+	rw.body.Reset()
+	if int64(len(body)) > rw.remaining {
+		return fmt.Errorf("response body exceeds its Content-Length")
+	}
+	n, err := rw.w.Write(body)
+	rw.bytesWritten += n
+	rw.remaining -= int64(n)
+	return err
+}
+
+// declaredLength returns the Content-Length set by the handler, if the body can be sent with it
+func (rw *Writer) declaredLength() (int64, bool) {
+	if !BodyAllowed(StatusCode(rw.statusCode)) || rw.headers.Get("Transfer-Encoding") != "" {
+		return 0, false
+	}
+	length, err := strconv.ParseInt(rw.headers.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+// OmitBody makes the Writer send the headers a full response would have, Content-Length
+// included, but no body, as a response to HEAD requires (RFC 9110 Section 9.3.2)
+func (rw *Writer) OmitBody() {
+	rw.omitBody = true
+}
+
+// DiscardBody drops anything buffered since the last Flush, keeping the headers
+func (rw *Writer) DiscardBody() {
+	rw.body.Reset()
+}
+
+// StatusCode returns the status that was or will be sent
+func (rw *Writer) StatusCode() int {
+	return rw.statusCode
+}
+
+// BytesWritten returns the number of body bytes sent so far, excluding chunk framing
+func (rw *Writer) BytesWritten() int {
+	return rw.bytesWritten
+}
+
+// Started reports whether the status line has been sent, after which it can't be replaced
+func (rw *Writer) Started() bool {
+	return rw.streaming || rw.done
+}
+
+// mergedHeaders overlays the handler's headers on the defaults for the buffered body.
+// Statuses that can't carry a body get no Content-Length or default Content-Type
+func (rw *Writer) mergedHeaders() headers.Headers {
+	h := GetDefaultHeaders(rw.body.Len())
+	if !BodyAllowed(StatusCode(rw.statusCode)) {
+		h.Del("Content-Length")
+		h.Del("Content-Type")
+	}
+	for k, v := range rw.headers {
+		h.Set(k, v)
+	}
+	// Without a body to measure, HEAD keeps a length the handler declared
+	if BodyAllowed(StatusCode(rw.statusCode)) && !(rw.omitBody && rw.headers.Get("Content-Length") != "") {
+		h.Set("Content-Length", strconv.Itoa(rw.body.Len()))
+	}
+	return h
+}
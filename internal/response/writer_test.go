@@ -0,0 +1,34 @@
+package response
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWriterFlush(t *testing.T) {
+	// Test: Flush streams the body with chunked encoding
+	buf := &bytes.Buffer{}
+	rw := NewWriter(buf)
+	_, _ = rw.Write([]byte("hello"))
+	require.NoError(t, rw.Flush())
+	require.NoError(t, rw.SendResponse())
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	assert.Contains(t, buf.String(), "\r\n\r\n5\r\nhello\r\n0\r\n\r\n")
+
+	// Test: Statuses that can't have a body get no framing, chunks or terminator
+	for _, status := range []int{204, 304} {
+		buf.Reset()
+		rw = NewWriter(buf)
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte("ignored"))
+		require.NoError(t, rw.Flush())
+		require.NoError(t, rw.SendResponse())
+		out := buf.String()
+		assert.NotContains(t, out, "Transfer-Encoding", status)
+		assert.NotContains(t, out, "Content-Length", status)
+		assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\n\r\n")), status)
+		assert.NotContains(t, out, "ignored", status)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// aLongTimeAgo is a read deadline in the past, used to unblock a pending Read
+var aLongTimeAgo = time.Unix(1, 0)
+
+// peerWatcher reads from a connection in the background while the handler runs,
+// cancelling the request context if the peer closes the connection
+type peerWatcher struct {
+	conn   net.Conn
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+	buf    [1]byte
+	n      int
+}
+
+// watchPeer starts watching conn, calling cancel when the peer goes away
+func watchPeer(conn net.Conn, cancel context.CancelFunc) *peerWatcher {
+	w := &peerWatcher{
+		conn:   conn,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *peerWatcher) run() {
+	defer close(w.done)
+
+	n, err := w.conn.Read(w.buf[:])
+	w.n = n
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		w.cancel()
+	}
+}
+
+// stop ends the background read and returns any byte it consumed from the connection.
+// It is safe to call more than once, only the first call returns the consumed byte
+func (w *peerWatcher) stop() []byte {
+	var consumed []byte
+	w.once.Do(func() {
+		_ = w.conn.SetReadDeadline(aLongTimeAgo)
+		<-w.done
+		_ = w.conn.SetReadDeadline(time.Time{})
+		consumed = w.buf[:w.n]
+	})
+	return consumed
+}
+
+// writeContext is the request context when WriteTimeout is set. Its deadline moves
+// forward on every Flush, so a streamed response is bounded by WriteTimeout between
+// writes rather than in total, and release drops the deadline for hijacked connections
+type writeContext struct {
+	parent     context.Context
+	timeout    time.Duration
+	done       chan struct{}
+	stopParent func() bool
+
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	err      error
+}
+
+// newWriteContext returns a context ending when parent does or timeout passes
+func newWriteContext(parent context.Context, timeout time.Duration) *writeContext {
+	c := &writeContext{
+		parent:   parent,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+		deadline: time.Now().Add(timeout),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = time.AfterFunc(timeout, func() { c.cancel(context.DeadlineExceeded) })
+	c.stopParent = context.AfterFunc(parent, func() { c.cancel(parent.Err()) })
+	return c
+}
+
+func (c *writeContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *writeContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *writeContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *writeContext) Value(key any) any {
+	return c.parent.Value(key)
+}
+
+// cancel ends the context with err, later calls have no effect
+func (c *writeContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.stopParent != nil {
+		c.stopParent()
+	}
+}
+
+// extend moves the deadline to timeout from now, unless the context ended or was released
+func (c *writeContext) extend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.deadline.IsZero() {
+		return
+	}
+	c.deadline = time.Now().Add(c.timeout)
+	c.timer.Reset(c.timeout)
+}
+
+// release removes the deadline, leaving the context to end only with its parent
+func (c *writeContext) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer.Stop()
+	c.deadline = time.Time{}
+}
@@ -1,49 +1,70 @@
 package server
 
 import (
+	"fmt"
+	"httpfromtcp/internal/headers"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 	"io"
-	"log"
 )
 
 type HandlerError struct {
 	StatusCode int
 	Message    string
+	// Problem, when set, is rendered as application/problem+json with Message as its detail
+	Problem *Problem
 }
 
 type Handler func(w io.Writer, req *request.Request) *HandlerError
 
-func WriteError(w io.Writer, h HandlerError) {
-	var statusCode response.StatusCode
-
-	switch h.StatusCode {
-	case 200:
-		statusCode = response.StatusOK
-	case 400:
-		statusCode = response.StatusBadRequest
-	case 500:
-		statusCode = response.StatusInternalError
-	default:
-		statusCode = response.StatusInternalError
+// Middleware wraps a Handler with additional behaviour
+type Middleware func(next Handler) Handler
+
+// Chain wraps h with middleware so that the first one listed runs first
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// ResponseWriter is implemented by the io.Writer passed to a Handler, and lets the
+// handler set the status and headers, or stream the body with Flush. The body is
+// otherwise buffered and sent with a Content-Length once the handler returns.
+// If the handler returns a HandlerError before streaming, the buffered body is replaced
+// with the error message but headers already set are kept
+type ResponseWriter interface {
+	io.Writer
+	Header() headers.Headers
+	WriteHeader(statusCode int)
+	Flush() error
+}
+
+// statusCode returns the error's status, falling back to 500 for values outside 100-599
+func (h HandlerError) statusCode() response.StatusCode {
+	if h.StatusCode < 100 || h.StatusCode > 599 {
+		return response.StatusInternalError
 	}
+	return response.StatusCode(h.StatusCode)
+}
 
-	err := response.WriteStatusLine(w, statusCode)
+// WriteError writes a HandlerError as a complete plain text response
+func WriteError(w io.Writer, h HandlerError) error {
+	err := response.WriteStatusLine(w, h.statusCode())
 	if err != nil {
-		log.Printf("Error writing status line: %v", err)
-		return
+		return fmt.Errorf("error writing status line: %v", err)
 	}
 
 	headers := response.GetDefaultHeaders(len(h.Message))
 	err = response.WriteHeaders(w, headers)
 	if err != nil {
-		log.Printf("Error getting default headers: %v", err)
-		return
+		return fmt.Errorf("error writing headers: %v", err)
 	}
 
 	_, err = w.Write([]byte(h.Message))
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
-		return
+		return fmt.Errorf("error writing response: %v", err)
 	}
+
+	return nil
 }
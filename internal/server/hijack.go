@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"httpfromtcp/internal/response"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrHijacked is returned when using a response writer after its connection was hijacked
+var ErrHijacked = errors.New("connection has been hijacked")
+
+// Hijacker is implemented by the io.Writer passed to a Handler, and lets the
+// handler take over the connection, e.g. for WebSocket or CONNECT tunnels.
+//
+// Hijack returns the raw connection and any bytes the server already read past
+// the end of the request. After a successful Hijack the server writes no response,
+// clears the connection deadlines and never closes the connection. The request is
+// reported to the access log and Observer with status 0.
+type Hijacker interface {
+	Hijack() (net.Conn, []byte, error)
+}
+
+// responseWriter is the io.Writer handed to handlers. It implements ResponseWriter
+// on top of response.Writer, and Hijacker
+type responseWriter struct {
+	*response.Writer
+	conn     net.Conn
+	buffered []byte
+	watcher  *peerWatcher
+	onHijack func()
+	onFlush  func() // Extends the write deadline while streaming
+
+	mu       sync.Mutex
+	hijacked bool
+}
+
+// Write buffers p as part of the response body
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if rw.isHijacked() {
+		return 0, ErrHijacked
+	}
+	return rw.Writer.Write(p)
+}
+
+// Flush streams the response written so far to the client
+func (rw *responseWriter) Flush() error {
+	if rw.isHijacked() {
+		return ErrHijacked
+	}
+	if rw.onFlush != nil {
+		rw.onFlush()
+	}
+	return rw.Writer.Flush()
+}
+
+// Hijack hands the connection over to the caller
+func (rw *responseWriter) Hijack() (net.Conn, []byte, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.hijacked {
+		return nil, nil, ErrHijacked
+	}
+	rw.hijacked = true
+
+	// Stop the peer watcher so it no longer reads from the connection, keeping anything it consumed
+	buffered := append(rw.buffered, rw.watcher.stop()...)
+	_ = rw.conn.SetDeadline(time.Time{})
+
+	if rw.onHijack != nil {
+		rw.onHijack()
+	}
+	return rw.conn, buffered, nil
+}
+
+// isHijacked reports whether the connection was hijacked
+func (rw *responseWriter) isHijacked() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.hijacked
+}
+
+var (
+	_ ResponseWriter = (*responseWriter)(nil)
+	_ Hijacker       = (*responseWriter)(nil)
+)
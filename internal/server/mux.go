@@ -0,0 +1,154 @@
+package server
+
+import (
+	"httpfromtcp/internal/request"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mux routes requests to handlers registered by method and path. A path ending in "/"
+// also matches everything below it, the longest registered path wins.
+// HEAD falls back to the GET handler, and OPTIONS, when not registered itself, is
+// answered with an Allow header listing the path's methods, or every method for "OPTIONS *"
+type Mux struct {
+	mu     sync.RWMutex
+	routes map[string]map[string]Handler // path -> method -> handler
+}
+
+// NewMux creates an empty Mux
+func NewMux() *Mux {
+	return &Mux{routes: map[string]map[string]Handler{}}
+}
+
+// Handle registers h for method requests to path
+func (m *Mux) Handle(method, path string, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.routes == nil {
+		m.routes = map[string]map[string]Handler{}
+	}
+	if m.routes[path] == nil {
+		m.routes[path] = map[string]Handler{}
+	}
+	m.routes[path][method] = h
+}
+
+// Allowed returns the methods path answers, sorted, or nil when nothing is routed there.
+// "*" returns the methods of every path
+func (m *Mux) Allowed(path string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if path == "*" {
+		methods := []string{"OPTIONS"}
+		for _, handlers := range m.routes {
+			methods = appendMethods(methods, handlers)
+		}
+		return methods
+	}
+	if handlers := m.match(path); handlers != nil {
+		return appendMethods(nil, handlers)
+	}
+	return nil
+}
+
+// Pattern returns the registered path that path is routed to, or "" when none matches
+func (m *Mux) Pattern(path string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.routes[path]; ok {
+		return path
+	}
+	return m.longestPrefix(path)
+}
+
+// Handler dispatches req to the handler registered for its method and path
+func (m *Mux) Handler(w io.Writer, req *request.Request) *HandlerError {
+	method, target := req.RequestLine.Method, req.RequestLine.RequestTarget
+	if target == "*" {
+		if method != "OPTIONS" {
+			return &HandlerError{StatusCode: 400, Message: "Invalid request target\n"}
+		}
+		return allow(w, m.Allowed("*"))
+	}
+
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return &HandlerError{StatusCode: 400, Message: "Invalid request target\n"}
+	}
+
+	m.mu.RLock()
+	handlers := m.match(u.Path)
+	h := handlers[method]
+	if h == nil && method == "HEAD" {
+		h = handlers["GET"]
+	}
+	m.mu.RUnlock()
+
+	switch {
+	case h != nil:
+		return h(w, req)
+	case handlers == nil:
+		return &HandlerError{StatusCode: 404, Message: "Not Found\n"}
+	case method == "OPTIONS":
+		return allow(w, m.Allowed(u.Path))
+	}
+	if rw, ok := w.(ResponseWriter); ok {
+		rw.Header().Set("Allow", strings.Join(m.Allowed(u.Path), ", "))
+	}
+	return &HandlerError{StatusCode: 405, Message: "Method Not Allowed\n"}
+}
+
+// match returns the handlers for path, exact matches first then the longest subtree.
+// The caller must hold m.mu
+func (m *Mux) match(path string) map[string]Handler {
+	if handlers, ok := m.routes[path]; ok {
+		return handlers
+	}
+	if best := m.longestPrefix(path); best != "" {
+		return m.routes[best]
+	}
+	return nil
+}
+
+// longestPrefix returns the longest registered subtree containing path.
+// The caller must hold m.mu
+func (m *Mux) longestPrefix(path string) string {
+	var best string
+	for p := range m.routes {
+		if strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) && len(p) > len(best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// appendMethods adds the methods of handlers to methods, with HEAD implied by GET and
+// OPTIONS always answered, returning the sorted set
+func appendMethods(methods []string, handlers map[string]Handler) []string {
+	for method := range handlers {
+		methods = append(methods, method)
+		if method == "GET" {
+			methods = append(methods, "HEAD")
+		}
+	}
+	methods = append(methods, "OPTIONS")
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// allow answers OPTIONS with 204 and the allowed methods
+func allow(w io.Writer, methods []string) *HandlerError {
+	rw, ok := w.(ResponseWriter)
+	if !ok {
+		return &HandlerError{StatusCode: 500, Message: "mux: response writer does not support headers\n"}
+	}
+	rw.Header().Set("Allow", strings.Join(methods, ", "))
+	rw.WriteHeader(204)
+	return nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"strings"
+	"testing"
+)
+
+// roundTrip sends a raw request to addr and returns the raw response
+func roundTrip(t *testing.T, addr, raw string) string {
+	t.Helper()
+	conn := dial(t, addr, raw)
+	defer conn.Close()
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(out)
+}
+
+// parse reads a raw response to a method request
+func parse(t *testing.T, method, raw string) *response.Response {
+	t.Helper()
+	resp, err := response.ResponseFromReader(strings.NewReader(raw), method)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestMux(t *testing.T) {
+	text := func(body string) Handler {
+		return func(w io.Writer, req *request.Request) *HandlerError {
+			_, _ = w.Write([]byte(body))
+			return nil
+		}
+	}
+	mux := NewMux()
+	mux.Handle("GET", "/items", text("all items"))
+	mux.Handle("POST", "/items", text("created"))
+	mux.Handle("GET", "/static/", text("static"))
+	mux.Handle("GET", "/stream", func(w io.Writer, req *request.Request) *HandlerError {
+		rw := w.(ResponseWriter)
+		_, _ = rw.Write([]byte("first"))
+		_ = rw.Flush()
+		_, _ = rw.Write([]byte("second"))
+		return nil
+	})
+	mux.Handle("GET", "/sized", func(w io.Writer, req *request.Request) *HandlerError {
+		// Handlers and proxies may declare the length without producing the body for HEAD
+		rw := w.(ResponseWriter)
+		rw.Header().Set("Content-Length", "1234")
+		if req.RequestLine.Method != "HEAD" {
+			_, _ = rw.Write([]byte(strings.Repeat("x", 1234)))
+		}
+		return nil
+	})
+	mux.Handle("DELETE", "/admin", text("deleted"))
+	mux.Handle("OPTIONS", "/admin", text("custom options"))
+	addr := startServer(t, &Server{Handler: mux.Handler})
+
+	// Test: Routes by method and path
+	resp := parse(t, "GET", roundTrip(t, addr, "GET /items?page=2 HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.Equal(t, "all items", string(resp.Body))
+	resp = parse(t, "POST", roundTrip(t, addr, "POST /items HTTP/1.1\r\nHost: localhost\r\nContent-Length: 0\r\n\r\n"))
+	assert.Equal(t, "created", string(resp.Body))
+	resp = parse(t, "GET", roundTrip(t, addr, "GET /static/css/site.css HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.Equal(t, "static", string(resp.Body))
+
+	// Test: HEAD runs the GET handler and keeps its Content-Length without sending the body
+	raw := roundTrip(t, addr, "HEAD /items HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+	resp = parse(t, "HEAD", raw)
+	assert.EqualValues(t, 200, resp.StatusLine.StatusCode)
+	assert.Equal(t, "9", resp.Headers.Get("Content-Length"))
+
+	// Test: A Content-Length set by the handler survives HEAD
+	raw = roundTrip(t, addr, "HEAD /sized HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+	assert.Equal(t, "1234", parse(t, "HEAD", raw).Headers.Get("Content-Length"))
+
+	// Test: HEAD of a streamed response sends the chunked head without any chunks
+	raw = roundTrip(t, addr, "HEAD /stream HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+	assert.NotContains(t, raw, "first")
+	assert.Equal(t, "chunked", parse(t, "HEAD", raw).Headers.Get("Transfer-Encoding"))
+
+	// Test: Unrouted OPTIONS lists the path's methods
+	resp = parse(t, "OPTIONS", roundTrip(t, addr, "OPTIONS /items HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.EqualValues(t, 204, resp.StatusLine.StatusCode)
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", resp.Headers.Get("Allow"))
+
+	// Test: OPTIONS * lists every registered method
+	resp = parse(t, "OPTIONS", roundTrip(t, addr, "OPTIONS * HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.EqualValues(t, 204, resp.StatusLine.StatusCode)
+	assert.Equal(t, "DELETE, GET, HEAD, OPTIONS, POST", resp.Headers.Get("Allow"))
+
+	// Test: A registered OPTIONS handler takes precedence
+	resp = parse(t, "OPTIONS", roundTrip(t, addr, "OPTIONS /admin HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.Equal(t, "custom options", string(resp.Body))
+
+	// Test: Other methods get 405 with Allow, unknown paths 404
+	resp = parse(t, "PUT", roundTrip(t, addr, "PUT /items HTTP/1.1\r\nHost: localhost\r\nContent-Length: 0\r\n\r\n"))
+	assert.EqualValues(t, 405, resp.StatusLine.StatusCode)
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", resp.Headers.Get("Allow"))
+	resp = parse(t, "HEAD", roundTrip(t, addr, "HEAD /admin HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.EqualValues(t, 405, resp.StatusLine.StatusCode)
+	assert.Equal(t, "DELETE, OPTIONS", resp.Headers.Get("Allow"))
+	resp = parse(t, "GET", roundTrip(t, addr, "GET /missing HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.EqualValues(t, 404, resp.StatusLine.StatusCode)
+
+	// Test: Errors answering HEAD keep their Content-Length but send no body
+	raw = roundTrip(t, addr, "HEAD /missing HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\n"), raw)
+	assert.Equal(t, "10", parse(t, "HEAD", raw).Headers.Get("Content-Length"))
+}
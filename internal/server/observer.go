@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives connection and request lifecycle events, e.g. for metrics collection
+type Observer interface {
+	ConnOpened()
+	ConnClosed(bytesRead, bytesWritten int64)
+	RequestStarted(req *request.Request)
+	RequestFinished(req *request.Request, status int, written int, duration time.Duration)
+	ParseError(kind string)
+}
+
+// nopObserver is used when the server has no Observer configured
+type nopObserver struct{}
+
+func (nopObserver) ConnOpened()                                               {}
+func (nopObserver) ConnClosed(int64, int64)                                   {}
+func (nopObserver) RequestStarted(*request.Request)                           {}
+func (nopObserver) RequestFinished(*request.Request, int, int, time.Duration) {}
+func (nopObserver) ParseError(string)                                         {}
+
+// countingConn wraps a net.Conn and tallies the bytes transferred in each direction
+type countingConn struct {
+	net.Conn
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// parseErrorKind classifies an error from request.RequestFromReader into a short label
+func parseErrorKind(err error) string {
+	switch {
+	case errors.Is(err, request.ErrInvalidRequestLine):
+		return "request_line"
+	case errors.Is(err, request.ErrInvalidMethod):
+		return "method"
+	case errors.Is(err, request.ErrInvalidVersion):
+		return "version"
+	case errors.Is(err, request.ErrInvalidContentLength):
+		return "content_length"
+	case errors.Is(err, request.ErrInvalidTransferEncoding):
+		return "transfer_encoding"
+	case errors.Is(err, request.ErrBodyTooLarge):
+		return "body_too_large"
+	case errors.Is(err, request.ErrIncompleteRequest):
+		return "incomplete"
+	case errors.Is(err, headers.ErrInvalidFormat), errors.Is(err, headers.ErrInvalidKey):
+		return "header"
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed):
+		return "io"
+	default:
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return "io"
+		}
+		return "other"
+	}
+}
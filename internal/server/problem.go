@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"httpfromtcp/internal/response"
+	"maps"
+	"strings"
+)
+
+// ProblemContentType is the media type of an RFC 9457 problem details document
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. Extensions are added as top level members
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// MarshalJSON flattens Extensions next to the standard members, omitting empty ones
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	maps.Copy(members, p.Extensions)
+	for name, value := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		if value != "" {
+			members[name] = value
+		} else {
+			delete(members, name)
+		}
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	return json.Marshal(members)
+}
+
+// problem returns the error as problem details, filling in status, title and detail
+func (h HandlerError) problem() Problem {
+	var p Problem
+	if h.Problem != nil {
+		p = *h.Problem
+	}
+	p.Status = int(h.statusCode())
+	if p.Title == "" {
+		p.Title = response.StatusText(h.statusCode())
+	}
+	if p.Detail == "" {
+		// Messages end in a newline for the plain text body, which a JSON member doesn't need
+		p.Detail = strings.TrimSuffix(h.Message, "\n")
+	}
+	return p
+}
+
+// render writes the error into w as problem details, or plain text unless asked otherwise
+func (h HandlerError) render(w ResponseWriter, problemDetails bool) {
+	body := []byte(h.Message)
+	contentType := "text/plain"
+	if problemDetails || h.Problem != nil {
+		if doc, err := json.Marshal(h.problem()); err == nil {
+			body, contentType = doc, ProblemContentType
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(int(h.statusCode()))
+	_, _ = w.Write(body)
+}
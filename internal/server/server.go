@@ -1,20 +1,41 @@
 package server
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"httpfromtcp/internal/accesslog"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
-	"log"
+	"log/slog"
 	"net"
 	"sync/atomic"
+	"time"
 )
 
 type Server struct {
-	Addr     string
-	Handler  Handler
-	Listener net.Listener
-	closed   atomic.Bool
+	Addr      string
+	Handler   Handler
+	Listener  net.Listener
+	Logger    *slog.Logger      // Logger for server errors, defaults to slog.Default()
+	AccessLog *accesslog.Logger // AccessLog records every completed request when set
+	Observer  Observer          // Observer receives lifecycle events, e.g. for metrics
+
+	// ProblemDetails renders every HandlerError as RFC 9457 application/problem+json
+	// instead of plain text. Errors with a Problem set are always rendered that way
+	ProblemDetails bool
+
+	// ReadTimeout bounds how long reading a request may take, zero means no timeout
+	ReadTimeout time.Duration
+	// WriteTimeout bounds handling and writing the response, and is set as the
+	// deadline of the request context. Each Flush extends both, so for streamed
+	// responses such as SSE it bounds the time between writes rather than the whole
+	// stream. Hijacked connections are released from it. Zero means no timeout
+	WriteTimeout time.Duration
+
+	baseCtx context.Context
+	cancel  context.CancelFunc
+	closed  atomic.Bool
 }
 
 // Serve creates HTTP Listener on a given port
@@ -27,6 +48,7 @@ func (s *Server) Serve(port int) (*Server, error) {
 
 	s.Addr = addr
 	s.Listener = lst
+	s.baseCtx, s.cancel = context.WithCancel(context.Background())
 	go s.listen()
 	return s, nil
 }
@@ -37,30 +59,49 @@ func (s *Server) Close() error {
 		return fmt.Errorf("server already closed")
 	}
 
+	// Signal in-flight handlers that the server is going away
+	s.cancel()
+
 	err := s.Listener.Close()
 	if err != nil {
 		return fmt.Errorf("error closing server listener: %v", err)
 	}
 
-	log.Println("Server has been closed gracefully")
+	s.logger().Info("Server has been closed gracefully")
 	return nil
 }
 
+// logger returns the configured Logger or the slog default
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// observer returns the configured Observer or a no-op implementation
+func (s *Server) observer() Observer {
+	if s.Observer != nil {
+		return s.Observer
+	}
+	return nopObserver{}
+}
+
 // listen is the Listener that is called by Serve
 func (s *Server) listen() {
 	for {
 		if s.closed.Load() {
-			log.Println("Server is shutting down, stopping listener")
+			s.logger().Info("Server is shutting down, stopping listener")
 			return
 		}
 
 		conn, err := s.Listener.Accept()
 		if err != nil {
 			if s.closed.Load() {
-				log.Println("Listener closed, exiting")
+				s.logger().Info("Listener closed, exiting")
 				return
 			}
-			log.Printf("Error accepting connection: %v", err)
+			s.logger().Error("Error accepting connection", "error", err)
 			continue
 		}
 		go s.handle(conn)
@@ -69,50 +110,165 @@ func (s *Server) listen() {
 }
 
 // handle is responsible for formatting a successful connection
-func (s *Server) handle(conn net.Conn) {
+func (s *Server) handle(netConn net.Conn) {
+	start := time.Now()
+	remoteAddr := netConn.RemoteAddr().String()
+	conn := &countingConn{Conn: netConn}
+	s.observer().ConnOpened()
+
+	// rw is set once the request is parsed, a hijacked connection belongs to the handler
+	var rw *responseWriter
+
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic: %v", r)
+			s.logger().Error("Recovered from panic", "panic", r, "remote_addr", remoteAddr)
 		}
+		if rw != nil && rw.isHijacked() {
+			return
+		}
+		s.observer().ConnClosed(conn.read.Load(), conn.written.Load())
 		err := conn.Close()
 		if err != nil {
-			log.Printf("Error closing connection: %v", err)
+			s.logger().Error("Error closing connection", "error", err, "remote_addr", remoteAddr)
 			return
 		}
 	}()
 
+	if s.ReadTimeout > 0 {
+		_ = conn.SetReadDeadline(start.Add(s.ReadTimeout))
+	}
+	if s.WriteTimeout > 0 {
+		_ = conn.SetWriteDeadline(start.Add(s.WriteTimeout))
+	}
+
 	req, err := request.RequestFromReader(conn)
 	if err != nil {
-		log.Printf("Error reading request: %v", err)
-		WriteError(conn, HandlerError{StatusCode: 400, Message: err.Error()})
+		s.logger().Error("Error reading request", "error", err, "remote_addr", remoteAddr)
+		s.observer().ParseError(parseErrorKind(err))
+		status := 400
+		if errors.Is(err, request.ErrBodyTooLarge) {
+			status = 413
+		}
+		err = WriteError(conn, HandlerError{StatusCode: status, Message: err.Error()})
+		if err != nil {
+			s.logger().Error("Error writing response", "error", err, "remote_addr", remoteAddr)
+		}
 		return
 	}
+	req.RemoteAddr = remoteAddr
+	_ = conn.SetReadDeadline(time.Time{})
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var writeCtx *writeContext
+	if s.WriteTimeout > 0 {
+		writeCtx = newWriteContext(s.baseCtx, s.WriteTimeout)
+		ctx, cancel = writeCtx, func() { writeCtx.cancel(context.Canceled) }
+	} else {
+		ctx, cancel = context.WithCancel(s.baseCtx)
+	}
+	defer cancel()
+	req = req.WithContext(ctx)
 
-	buffer := &bytes.Buffer{}
+	watcher := watchPeer(conn, cancel)
+	defer watcher.stop()
 
-	handlerErr := s.Handler(buffer, req)
-	if handlerErr != nil {
-		WriteError(conn, *handlerErr)
-		return
+	// Tag the request with an ID so log lines can be correlated
+	if req.Headers.Get("X-Request-Id") == "" {
+		req.Headers["x-request-id"] = accesslog.NewRequestID()
 	}
 
-	headers := response.GetDefaultHeaders(buffer.Len())
+	// Report a 500 if the handler panics before a status is known
+	status, written := int(response.StatusInternalError), 0
+	s.observer().RequestStarted(req)
+	defer func() {
+		s.observer().RequestFinished(req, status, written, time.Since(start))
+		s.logAccess(req, start, status, written)
+	}()
 
-	err = response.WriteStatusLine(conn, response.StatusOK)
-	if err != nil {
-		log.Printf("Error writing response: %v", err)
-		return
+	rw = &responseWriter{
+		Writer:   response.NewWriter(conn),
+		conn:     netConn,
+		buffered: req.Buffered(),
+		watcher:  watcher,
+		onHijack: func() {
+			// The connection now belongs to the handler, only shutdown ends its context
+			if writeCtx != nil {
+				writeCtx.release()
+			}
+			s.observer().ConnClosed(conn.read.Load(), conn.written.Load())
+		},
+	}
+	if writeCtx != nil {
+		rw.onFlush = func() {
+			writeCtx.extend()
+			_ = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+		}
+	}
+	// HEAD runs the handler as for GET, only the body is left out
+	if req.RequestLine.Method == "HEAD" {
+		rw.OmitBody()
+	}
+	status, written = s.respond(rw, req)
+}
+
+// respond runs the handler and writes its response, returning the status and body size sent
+func (s *Server) respond(rw *responseWriter, req *request.Request) (int, int) {
+	handlerErr := s.Handler(rw, req)
+	if rw.isHijacked() {
+		if handlerErr != nil {
+			s.logger().Error("Handler error after hijack", "status", handlerErr.StatusCode, "message", handlerErr.Message)
+		}
+		return 0, 0
 	}
 
-	err = response.WriteHeaders(conn, headers)
+	if handlerErr != nil {
+		// Once streaming has started the status is already on the wire
+		if rw.Started() {
+			s.logger().Error("Handler error after response started", "status", handlerErr.StatusCode, "message", handlerErr.Message)
+		} else {
+			// Keep headers the handler set, e.g. Allow or WWW-Authenticate, but replace the body
+			rw.DiscardBody()
+			handlerErr.render(rw, s.ProblemDetails)
+		}
+	}
+
+	err := rw.SendResponse()
 	if err != nil {
-		log.Printf("Error writing headers: %v", err)
+		s.logger().Error("Error writing response", "error", err, "remote_addr", req.RemoteAddr)
+	}
+	return rw.StatusCode(), rw.BytesWritten()
+}
+
+// logAccess writes an access log entry for a completed request
+func (s *Server) logAccess(req *request.Request, start time.Time, status, written int) {
+	if s.AccessLog == nil {
 		return
 	}
 
-	_, err = conn.Write(buffer.Bytes())
+	err := s.AccessLog.Log(accesslog.Entry{
+		Time:         start,
+		Method:       req.RequestLine.Method,
+		Target:       req.RequestLine.RequestTarget,
+		Proto:        req.RequestLine.HttpVersion,
+		Status:       status,
+		Bytes:        written,
+		RequestBytes: requestBytes(req),
+		Duration:     time.Since(start),
+		RemoteAddr:   req.RemoteAddr,
+		UserAgent:    req.Headers.Get("User-Agent"),
+		Referer:      req.Headers.Get("Referer"),
+		RequestID:    req.Headers.Get("X-Request-Id"),
+	})
 	if err != nil {
-		log.Printf("Error writing response: %v", err)
-		return
+		s.logger().Error("Error writing access log", "error", err)
+	}
+}
+
+// requestBytes returns the size of the request body as it was received
+func requestBytes(req *request.Request) int {
+	if req.EncodedBodyLength > 0 {
+		return req.EncodedBodyLength
 	}
+	return len(req.Body)
 }
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handler on a random local port and returns the dial address
+func startServer(t *testing.T, srv *Server) string {
+	t.Helper()
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv.Listener.Addr().String()
+}
+
+// dial connects to addr and writes a raw request
+func dial(t *testing.T, addr, raw string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = fmt.Fprint(conn, raw)
+	require.NoError(t, err)
+	return conn
+}
+
+func TestRequestContext(t *testing.T) {
+	// Test: Context is cancelled when the client disconnects
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	addr := startServer(t, &Server{
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			close(started)
+			<-req.Context().Done()
+			cancelled <- req.Context().Err()
+			return nil
+		},
+	})
+
+	conn := dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	<-started
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context was not cancelled on client disconnect")
+	}
+
+	// Test: Context has a deadline from WriteTimeout
+	srv := &Server{
+		WriteTimeout: time.Minute,
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			deadline, ok := req.Context().Deadline()
+			if !ok || time.Until(deadline) > time.Minute {
+				return &HandlerError{StatusCode: 500, Message: "missing deadline"}
+			}
+			_, _ = w.Write([]byte("ok"))
+			return nil
+		},
+	}
+	addr = startServer(t, srv)
+	conn = dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "HTTP/1.1 200 OK\r\n")
+
+	// Test: Context is cancelled when the server closes
+	started = make(chan struct{})
+	srv = &Server{
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			close(started)
+			<-req.Context().Done()
+			cancelled <- req.Context().Err()
+			return nil
+		},
+	}
+	addr = startServer(t, srv)
+	conn = dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	defer conn.Close()
+	<-started
+	require.NoError(t, srv.Close())
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context was not cancelled on server close")
+	}
+}
+
+func TestHijack(t *testing.T) {
+	// Test: Handler takes over the connection and receives bytes sent after the request
+	done := make(chan struct{})
+	addr := startServer(t, &Server{
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			hj, ok := w.(Hijacker)
+			if !ok {
+				return &HandlerError{StatusCode: 500, Message: "not a hijacker"}
+			}
+			conn, buffered, err := hj.Hijack()
+			if err != nil {
+				return &HandlerError{StatusCode: 500, Message: err.Error()}
+			}
+
+			// Further writes through the response writer are rejected
+			if _, err := w.Write([]byte("nope")); !errors.Is(err, ErrHijacked) {
+				panic("expected ErrHijacked")
+			}
+
+			// Echo the buffered bytes and the rest of the stream in our own protocol
+			go func() {
+				defer close(done)
+				defer conn.Close()
+				_, _ = conn.Write([]byte("CUSTOM\n"))
+				_, _ = conn.Write(buffered)
+				_, _ = io.Copy(conn, conn)
+			}()
+			return nil
+		},
+	})
+
+	conn := dial(t, addr, "GET /upgrade HTTP/1.1\r\nHost: localhost\r\n\r\nhello ")
+	defer conn.Close()
+
+	buf := make([]byte, len("CUSTOM\nhello "))
+	_, err := io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "CUSTOM\nhello ", string(buf))
+
+	// Test: The connection stays open after the handler returns
+	_, err = conn.Write([]byte("world"))
+	require.NoError(t, err)
+	buf = make([]byte, len("world"))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(buf))
+
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	<-done
+}
+
+func TestWriteTimeout(t *testing.T) {
+	// Test: A handler that never writes is cut off at WriteTimeout
+	timedOut := make(chan error, 1)
+	addr := startServer(t, &Server{
+		WriteTimeout: 50 * time.Millisecond,
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			<-req.Context().Done()
+			timedOut <- req.Context().Err()
+			return nil
+		},
+	})
+	conn := dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	defer conn.Close()
+	select {
+	case err := <-timedOut:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(2 * time.Second):
+		t.Fatal("context did not time out")
+	}
+
+	// Test: Each Flush extends the deadline, so a stream can outlive WriteTimeout
+	addr = startServer(t, &Server{
+		WriteTimeout: 100 * time.Millisecond,
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			rw := w.(ResponseWriter)
+			for i := range 6 {
+				select {
+				case <-req.Context().Done():
+					return nil
+				case <-time.After(40 * time.Millisecond):
+				}
+				fmt.Fprintf(rw, "%d", i)
+				if err := rw.Flush(); err != nil {
+					return nil
+				}
+			}
+			return nil
+		},
+	})
+	out, err := io.ReadAll(dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "1\r\n5\r\n0\r\n\r\n")
+
+	// Test: Hijacked connections are released from WriteTimeout
+	alive := make(chan error, 1)
+	addr = startServer(t, &Server{
+		WriteTimeout: 50 * time.Millisecond,
+		Handler: func(w io.Writer, req *request.Request) *HandlerError {
+			c, _, err := w.(Hijacker).Hijack()
+			if err != nil {
+				return &HandlerError{StatusCode: 500, Message: err.Error()}
+			}
+			defer c.Close()
+			time.Sleep(150 * time.Millisecond)
+			_, deadline := req.Context().Deadline()
+			if deadline {
+				alive <- errors.New("deadline still set")
+				return nil
+			}
+			alive <- req.Context().Err()
+			return nil
+		},
+	})
+	conn = dial(t, addr, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	defer conn.Close()
+	assert.NoError(t, <-alive)
+}
+
+func TestParseError(t *testing.T) {
+	// Test: A malformed request is answered with 400
+	addr := startServer(t, &Server{
+		Handler: func(w io.Writer, req *request.Request) *HandlerError { return nil },
+	})
+	conn := dial(t, addr, "GET / HTTP/1.1\r\nHost localhost\r\n\r\n")
+	defer conn.Close()
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 400 Bad Request\r\n"))
+
+	// Test: A chunked body over request.MaxChunkedBodyBytes is answered with 413
+	conn, err = net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		size := request.MaxChunkedBodyBytes + 1
+		_, _ = fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n0\r\n\r\n", size, strings.Repeat("a", size))
+	}()
+	out, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), "HTTP/1.1 413 Content Too Large\r\n"), string(out))
+}
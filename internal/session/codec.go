@@ -0,0 +1,133 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by Codec.Decode
+var (
+	ErrInvalidCookie = errors.New("invalid session cookie")
+	ErrExpired       = errors.New("session cookie expired")
+)
+
+// MinSecretLength is the shortest secret accepted by NewCodec
+const MinSecretLength = 32
+
+// Codec signs cookie values with HMAC-SHA256, optionally encrypting them with AES-GCM first.
+// The first secret is used to encode, all of them are tried when decoding so old
+// secrets can be kept around while cookies issued with them expire
+type Codec struct {
+	keys    []codecKey
+	encrypt bool
+}
+
+// codecKey holds the keys derived from one secret
+type codecKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// NewCodec creates a Codec from secrets of at least MinSecretLength bytes, newest first
+func NewCodec(encrypt bool, secrets ...[]byte) (*Codec, error) {
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("session: at least one secret is required")
+	}
+
+	c := &Codec{encrypt: encrypt}
+	for i, secret := range secrets {
+		if len(secret) < MinSecretLength {
+			return nil, fmt.Errorf("session: secret %d is shorter than %d bytes", i, MinSecretLength)
+		}
+		block, err := aes.NewCipher(derive(secret, "encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = append(c.keys, codecKey{sign: derive(secret, "sign"), aead: aead})
+	}
+	return c, nil
+}
+
+// derive produces independent 32 byte keys for signing and encryption from a secret
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("httpfromtcp session " + purpose))
+	return mac.Sum(nil)
+}
+
+// Encode protects data for a cookie called name, stamping it with the current time
+func (c *Codec) Encode(name string, data []byte) (string, error) {
+	key := c.keys[0]
+
+	msg := binary.BigEndian.AppendUint64(nil, uint64(time.Now().Unix()))
+	if c.encrypt {
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		msg = append(msg, nonce...)
+		msg = key.aead.Seal(msg, nonce, data, []byte(name))
+	} else {
+		msg = append(msg, data...)
+	}
+
+	msg = append(msg, sign(key.sign, name, msg)...)
+	return base64.RawURLEncoding.EncodeToString(msg), nil
+}
+
+// Decode verifies a value produced by Encode for the same cookie name and returns its data.
+// Values issued more than maxAge ago are rejected, zero disables the check
+func (c *Codec) Decode(name, value string, maxAge time.Duration) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) < 8+sha256.Size {
+		return nil, ErrInvalidCookie
+	}
+	msg, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+
+	for _, key := range c.keys {
+		if !hmac.Equal(mac, sign(key.sign, name, msg)) {
+			continue
+		}
+
+		issued := time.Unix(int64(binary.BigEndian.Uint64(msg[:8])), 0)
+		if maxAge > 0 && time.Since(issued) > maxAge {
+			return nil, ErrExpired
+		}
+		data := msg[8:]
+		if !c.encrypt {
+			return data, nil
+		}
+
+		nonceSize := key.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, ErrInvalidCookie
+		}
+		plain, err := key.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(name))
+		if err != nil {
+			return nil, ErrInvalidCookie
+		}
+		return plain, nil
+	}
+	return nil, ErrInvalidCookie
+}
+
+// sign computes the MAC of a message bound to the cookie name
+func sign(key []byte, name string, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
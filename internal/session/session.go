@@ -0,0 +1,283 @@
+// Package session keeps login state in signed, optionally encrypted, cookies.
+// Values live in the cookie itself, or in a Store keyed by a session ID carried in the cookie
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/cookie"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"maps"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultStoreLifetime is how long a Store keeps a session whose cookie has no MaxAge,
+// a browser session cookie can't be relied on to tell the server when it is gone
+const DefaultStoreLifetime = 24 * time.Hour
+
+// MaxCookieSize is the largest Set-Cookie value browsers are required to store (RFC 6265 Section 6.1)
+const MaxCookieSize = 4096
+
+// Session holds the values of one client's session, it is safe for concurrent use
+type Session struct {
+	mu        sync.Mutex
+	id        string
+	values    map[string]string
+	modified  bool
+	destroyed bool
+	staleID   string // ID to delete from the Store after Renew
+}
+
+// Get returns the value for key, or "" if it is not set
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// Set stores value under key
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.modified = true
+}
+
+// Delete removes key
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.modified = true
+}
+
+// Values returns a copy of every value in the session
+func (s *Session) Values() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.values)
+}
+
+// Renew keeps the values under a fresh session ID, call it on login to prevent session fixation
+func (s *Session) Renew() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.staleID == "" {
+		s.staleID = s.id
+	}
+	s.id = ""
+	s.modified = true
+}
+
+// Destroy clears the session and expires its cookie, e.g. on logout
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]string{}
+	s.destroyed = true
+}
+
+type contextKey struct{}
+
+// FromRequest returns the session attached by Manager.Middleware, or nil outside of it
+func FromRequest(req *request.Request) *Session {
+	s, _ := req.Context().Value(contextKey{}).(*Session)
+	return s
+}
+
+// Manager loads sessions from and saves them to cookies
+type Manager struct {
+	Codec *Codec
+	// Store keeps values server side when set, otherwise they are stored in the cookie
+	Store Store
+
+	CookieName string
+	MaxAge     time.Duration // Zero makes a browser session cookie, see DefaultStoreLifetime
+	Path       string
+	Domain     string
+	Secure     bool
+	SameSite   cookie.SameSite
+}
+
+// New creates a Manager with a "session" cookie on Path "/" that lasts 24 hours
+func New(codec *Codec) *Manager {
+	return &Manager{
+		Codec:      codec,
+		CookieName: "session",
+		MaxAge:     24 * time.Hour,
+		Path:       "/",
+		SameSite:   cookie.SameSiteLax,
+	}
+}
+
+// Middleware attaches the request's session and sends an updated cookie if it changed
+func (m *Manager) Middleware(next server.Handler) server.Handler {
+	return func(w io.Writer, req *request.Request) *server.HandlerError {
+		s, err := m.load(req)
+		if err != nil {
+			return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error loading session: %v\n", err)}
+		}
+		req = req.WithContext(context.WithValue(req.Context(), contextKey{}, s))
+
+		rw, ok := w.(server.ResponseWriter)
+		if !ok {
+			return next(w, req)
+		}
+
+		sw := &sessionWriter{ResponseWriter: rw, commit: func() error { return m.save(req.Context(), rw, s) }}
+		handlerErr := next(sw, req)
+		if err := sw.commitOnce(); err != nil && handlerErr == nil {
+			return &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("Error saving session: %v\n", err)}
+		}
+		return handlerErr
+	}
+}
+
+// load decodes the session cookie, starting an empty session if it is missing or invalid
+func (m *Manager) load(req *request.Request) (*Session, error) {
+	s := &Session{values: map[string]string{}}
+
+	c, err := cookie.Get(req, m.CookieName)
+	if err != nil {
+		return s, nil
+	}
+	data, err := m.Codec.Decode(m.CookieName, c.Value, m.MaxAge)
+	if err != nil {
+		// Tampered, expired or signed with a retired secret, the client gets a fresh session
+		return s, nil
+	}
+
+	if m.Store == nil {
+		if err := json.Unmarshal(data, &s.values); err != nil {
+			s.values = map[string]string{}
+		}
+		return s, nil
+	}
+
+	values, found, err := m.Store.Load(req.Context(), string(data))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		s.id, s.values = string(data), values
+	}
+	return s, nil
+}
+
+// save writes the Set-Cookie header for a modified or destroyed session
+func (m *Manager) save(ctx context.Context, rw server.ResponseWriter, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &cookie.Cookie{
+		Name:     m.CookieName,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		Secure:   m.Secure,
+		HttpOnly: true,
+		SameSite: m.SameSite,
+	}
+
+	if m.Store != nil && s.staleID != "" {
+		if err := m.Store.Delete(ctx, s.staleID); err != nil {
+			return err
+		}
+		s.staleID = ""
+	}
+
+	if s.destroyed {
+		if m.Store != nil && s.id != "" {
+			if err := m.Store.Delete(ctx, s.id); err != nil {
+				return err
+			}
+		}
+		c.MaxAge = -1
+		return cookie.Set(rw.Header(), c)
+	}
+	if !s.modified {
+		return nil
+	}
+
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	if m.Store != nil {
+		if s.id == "" {
+			if s.id, err = newID(); err != nil {
+				return err
+			}
+		}
+		lifetime := m.MaxAge
+		if lifetime <= 0 {
+			lifetime = DefaultStoreLifetime
+		}
+		if err := m.Store.Save(ctx, s.id, s.values, time.Now().Add(lifetime)); err != nil {
+			return err
+		}
+		data = []byte(s.id)
+	}
+
+	c.Value, err = m.Codec.Encode(m.CookieName, data)
+	if err != nil {
+		return err
+	}
+	if m.MaxAge > 0 {
+		c.MaxAge = int(m.MaxAge.Seconds())
+	}
+	if len(c.String()) > MaxCookieSize {
+		return errors.New("session cookie is too large, use a Store")
+	}
+	return cookie.Set(rw.Header(), c)
+}
+
+// newID returns a random session ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionWriter saves the session before headers are streamed
+type sessionWriter struct {
+	server.ResponseWriter
+	commit    func() error
+	committed bool
+}
+
+// commitOnce runs commit the first time it is called
+func (sw *sessionWriter) commitOnce() error {
+	if sw.committed {
+		return nil
+	}
+	sw.committed = true
+	return sw.commit()
+}
+
+// Flush saves the session first, since the headers are sent with the first chunk
+func (sw *sessionWriter) Flush() error {
+	if err := sw.commitOnce(); err != nil {
+		return err
+	}
+	return sw.ResponseWriter.Flush()
+}
+
+// Hijack passes through to the server, the session is not saved on hijacked connections
+func (sw *sessionWriter) Hijack() (net.Conn, []byte, error) {
+	hj, ok := sw.ResponseWriter.(server.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("session: response writer cannot be hijacked")
+	}
+	sw.committed = true
+	return hj.Hijack()
+}
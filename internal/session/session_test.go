@@ -0,0 +1,220 @@
+package session
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldSecret = bytes.Repeat([]byte("o"), MinSecretLength)
+	newSecret = bytes.Repeat([]byte("n"), MinSecretLength)
+)
+
+func TestCodec(t *testing.T) {
+	// Test: Secrets must be long enough
+	_, err := NewCodec(false, []byte("short"))
+	require.Error(t, err)
+
+	for _, encrypt := range []bool{false, true} {
+		codec, err := NewCodec(encrypt, oldSecret)
+		require.NoError(t, err)
+
+		// Test: Round trip
+		value, err := codec.Encode("session", []byte(`{"user":"gopher"}`))
+		require.NoError(t, err)
+		data, err := codec.Decode("session", value, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, `{"user":"gopher"}`, string(data))
+
+		// Test: Only encrypted values hide their content
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		require.NoError(t, err)
+		assert.Equal(t, !encrypt, bytes.Contains(raw, []byte("gopher")))
+
+		// Test: Tampering, a different cookie name and garbage are rejected
+		raw[len(raw)/2] ^= 1
+		_, err = codec.Decode("session", base64.RawURLEncoding.EncodeToString(raw), time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidCookie)
+		_, err = codec.Decode("other", value, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidCookie)
+		_, err = codec.Decode("session", "not base64!", time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidCookie)
+
+		// Test: Rotation decodes values from the old secret, dropping it invalidates them
+		rotated, err := NewCodec(encrypt, newSecret, oldSecret)
+		require.NoError(t, err)
+		_, err = rotated.Decode("session", value, time.Hour)
+		assert.NoError(t, err)
+		retired, err := NewCodec(encrypt, newSecret)
+		require.NoError(t, err)
+		_, err = retired.Decode("session", value, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidCookie)
+	}
+
+	// Test: Values older than maxAge are expired
+	codec, err := NewCodec(false, oldSecret)
+	require.NoError(t, err)
+	value, err := codec.Encode("session", []byte("x"))
+	require.NoError(t, err)
+	raw, _ := base64.RawURLEncoding.DecodeString(value)
+	msg := raw[:len(raw)-32]
+	// Pretend it was issued ten seconds earlier
+	binary.BigEndian.PutUint64(msg[:8], binary.BigEndian.Uint64(msg[:8])-10)
+	value = base64.RawURLEncoding.EncodeToString(append(msg, sign(codec.keys[0].sign, "session", msg)...))
+	_, err = codec.Decode("session", value, 5*time.Second)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+var setCookieRegex = regexp.MustCompile(`(?i)set-cookie: (session=[^;\r]*)([^\r]*)`)
+
+// visit sends a request with an optional Cookie header and returns the body and new cookie
+func visit(t *testing.T, addr, path, cookie string) (string, string, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	if cookie != "" {
+		cookie = "Cookie: " + cookie + "\r\n"
+	}
+	_, err = fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\n%s\r\n", path, cookie)
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	head, body, _ := strings.Cut(string(out), "\r\n\r\n")
+	m := setCookieRegex.FindStringSubmatch(head)
+	if m == nil {
+		return body, "", ""
+	}
+	return body, m[1], m[2]
+}
+
+// counter counts visits, /renew renews the session and /logout destroys it
+func counter(w io.Writer, req *request.Request) *server.HandlerError {
+	s := FromRequest(req)
+	switch req.RequestLine.RequestTarget {
+	case "/logout":
+		s.Destroy()
+	case "/renew":
+		s.Renew()
+	case "/peek":
+	default:
+		s.Set("visits", s.Get("visits")+"+")
+	}
+	_, _ = w.Write([]byte(s.Get("visits")))
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, store := range []Store{nil, NewMemoryStore()} {
+		codec, err := NewCodec(true, newSecret)
+		require.NoError(t, err)
+		m := New(codec)
+		m.Store = store
+		srv := &server.Server{Handler: m.Middleware(counter)}
+		_, err = srv.Serve(0)
+		require.NoError(t, err)
+		addr := srv.Listener.Addr().String()
+
+		// Test: A new session is saved in a signed HttpOnly cookie
+		body, c, attrs := visit(t, addr, "/", "")
+		assert.Equal(t, "+", body)
+		require.NotEmpty(t, c)
+		assert.Contains(t, attrs, "; Path=/; Max-Age=86400; HttpOnly; SameSite=Lax")
+
+		// Test: The cookie carries the session to the next request
+		body, c, _ = visit(t, addr, "/", c)
+		assert.Equal(t, "++", body)
+
+		// Test: Unmodified sessions send no cookie
+		body, unchanged, _ := visit(t, addr, "/peek", c)
+		assert.Equal(t, "++", body)
+		assert.Empty(t, unchanged)
+
+		// Test: Renew issues a new cookie with the same values
+		body, renewed, _ := visit(t, addr, "/renew", c)
+		assert.Equal(t, "++", body)
+		require.NotEmpty(t, renewed)
+		body, _, _ = visit(t, addr, "/peek", renewed)
+		assert.Equal(t, "++", body)
+		if store != nil {
+			// The old ID no longer resolves once renewed
+			body, _, _ = visit(t, addr, "/peek", c)
+			assert.Equal(t, "", body)
+		}
+
+		// Test: A forged cookie starts a fresh session
+		body, _, _ = visit(t, addr, "/peek", "session=forged")
+		assert.Equal(t, "", body)
+
+		// Test: Destroy expires the cookie
+		_, cleared, attrs := visit(t, addr, "/logout", renewed)
+		assert.Equal(t, "session=", cleared)
+		assert.Contains(t, attrs, "Max-Age=0")
+		if store != nil {
+			body, _, _ = visit(t, addr, "/peek", renewed)
+			assert.Equal(t, "", body)
+		}
+
+		require.NoError(t, srv.Close())
+	}
+}
+
+func TestMiddlewareSessionCookie(t *testing.T) {
+	codec, err := NewCodec(true, newSecret)
+	require.NoError(t, err)
+	m := New(codec)
+	m.Store = NewMemoryStore()
+	m.MaxAge = 0
+	srv := &server.Server{Handler: m.Middleware(counter)}
+	_, err = srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// Test: Without MaxAge the cookie has no Max-Age but the Store still keeps the session
+	_, c, attrs := visit(t, addr, "/", "")
+	require.NotEmpty(t, c)
+	assert.NotContains(t, attrs, "Max-Age")
+	body, _, _ := visit(t, addr, "/peek", c)
+	assert.Equal(t, "+", body)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := t.Context()
+
+	// Test: Saved values are copied and expire
+	values := map[string]string{"a": "1"}
+	require.NoError(t, store.Save(ctx, "live", values, time.Now().Add(time.Hour)))
+	require.NoError(t, store.Save(ctx, "dead", values, time.Now().Add(-time.Second)))
+	values["a"] = "changed"
+
+	got, found, err := store.Load(ctx, "live")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]string{"a": "1"}, got)
+
+	_, found, err = store.Load(ctx, "dead")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// Test: Delete and Cleanup
+	require.NoError(t, store.Save(ctx, "dead", values, time.Now().Add(-time.Second)))
+	store.Cleanup()
+	assert.Len(t, store.sessions, 1)
+	require.NoError(t, store.Delete(ctx, "live"))
+	assert.Empty(t, store.sessions)
+}
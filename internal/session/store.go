@@ -0,0 +1,78 @@
+package session
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Store keeps session values server side, so the cookie only carries the session ID
+type Store interface {
+	// Load returns the values saved for id, or found false if there are none or they expired
+	Load(ctx context.Context, id string) (values map[string]string, found bool, err error)
+	// Save replaces the values for id, to be forgotten after expires
+	Save(ctx context.Context, id string, values map[string]string, expires time.Time) error
+	// Delete forgets id
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is a Store held in process memory, suitable for a single server
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	values  map[string]string
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memoryEntry{}}
+}
+
+func (s *MemoryStore) Load(_ context.Context, id string) (map[string]string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.sessions[id]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.sessions, id)
+		return nil, false, nil
+	}
+	return maps.Clone(entry.values), true, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, id string, values map[string]string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[id] = memoryEntry{values: maps.Clone(values), expires: expires}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// Cleanup removes expired sessions, call it periodically for long running servers
+func (s *MemoryStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range s.sessions {
+		if now.After(entry.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
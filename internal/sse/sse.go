@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/server"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a single Server-Sent Event. Empty fields are omitted from the stream
+type Event struct {
+	ID    string
+	Event string
+	Data  string        // May contain newlines, each line is sent as its own data field
+	Retry time.Duration // Reconnection delay for the client, sent in milliseconds
+}
+
+// Stream writes events to a streaming text/event-stream response
+type Stream struct {
+	mu sync.Mutex
+	w  server.ResponseWriter
+}
+
+// lineBreaks normalises CRLF and CR to LF before splitting multi-line values
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// NewStream sets the event stream headers and sends them, switching the response to streaming
+func NewStream(w io.Writer) (*Stream, error) {
+	rw, ok := w.(server.ResponseWriter)
+	if !ok {
+		return nil, fmt.Errorf("sse: response writer does not support streaming")
+	}
+
+	h := rw.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	rw.WriteHeader(200)
+
+	err := rw.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{w: rw}, nil
+}
+
+// LastEventID returns the ID of the last event a reconnecting client received, or ""
+func LastEventID(req *request.Request) string {
+	return req.Headers.Get("Last-Event-ID")
+}
+
+// Send writes a single event and flushes it to the client
+func (s *Stream) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n\x00") {
+		return fmt.Errorf("sse: event id must not contain newlines or NUL")
+	}
+	if strings.ContainsAny(e.Event, "\r\n") {
+		return fmt.Errorf("sse: event type must not contain newlines")
+	}
+
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if e.Data != "" {
+		for _, line := range strings.Split(lineBreaks.Replace(e.Data), "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which clients ignore, e.g. to keep the connection alive
+func (s *Stream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(lineBreaks.Replace(text), "\n") {
+		b.WriteString(": " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Run sends events until the channel is closed or ctx is done, writing a heartbeat
+// comment whenever heartbeat elapses without an event. Pass the request's context
+// so the stream stops when the client disconnects. A zero heartbeat disables it
+func (s *Stream) Run(ctx context.Context, events <-chan Event, heartbeat time.Duration) error {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := s.Send(e); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := s.Comment("heartbeat"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// write sends a serialised event as its own chunk
+func (s *Stream) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := io.WriteString(s.w, data)
+	if err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
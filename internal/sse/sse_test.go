@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := response.NewWriter(buf)
+
+	// Test: Headers are sent immediately without a Content-Length
+	s, err := NewStream(w)
+	require.NoError(t, err)
+	head := buf.String()
+	assert.True(t, strings.HasPrefix(head, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, head, "Content-Type: text/event-stream\r\n")
+	assert.Contains(t, head, "Transfer-Encoding: chunked\r\n")
+	assert.NotContains(t, head, "Content-Length")
+
+	// Test: All fields with multi-line data, sent as one chunk
+	buf.Reset()
+	require.NoError(t, s.Send(Event{ID: "7", Event: "update", Data: "line one\r\nline two", Retry: 3 * time.Second}))
+	event := "id: 7\nevent: update\nretry: 3000\ndata: line one\ndata: line two\n\n"
+	assert.Equal(t, fmt.Sprintf("%x\r\n%s\r\n", len(event), event), buf.String())
+
+	// Test: Comment
+	buf.Reset()
+	require.NoError(t, s.Comment("heartbeat"))
+	assert.Equal(t, "d\r\n: heartbeat\n\n\r\n", buf.String())
+
+	// Test: Newlines in the ID are rejected
+	assert.Error(t, s.Send(Event{ID: "1\n2", Data: "x"}))
+
+	// Test: Run stops when the channel closes and ends the body
+	buf.Reset()
+	events := make(chan Event, 1)
+	events <- Event{Data: "last"}
+	close(events)
+	require.NoError(t, s.Run(context.Background(), events, time.Hour))
+	require.NoError(t, w.SendResponse())
+	assert.Equal(t, "c\r\ndata: last\n\n\r\n0\r\n\r\n", buf.String())
+}
+
+func TestStreamServer(t *testing.T) {
+	stopped := make(chan error, 1)
+	srv := &server.Server{
+		Handler: func(w io.Writer, req *request.Request) *server.HandlerError {
+			s, err := NewStream(w)
+			if err != nil {
+				return &server.HandlerError{StatusCode: 500, Message: err.Error()}
+			}
+			events := make(chan Event, 1)
+			events <- Event{ID: "2", Data: "resumed after " + LastEventID(req)}
+			stopped <- s.Run(req.Context(), events, 10*time.Millisecond)
+			return nil
+		},
+	}
+	_, err := srv.Serve(0)
+	require.NoError(t, err)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprint(conn, "GET /events HTTP/1.1\r\nHost: localhost\r\nLast-Event-ID: 1\r\n\r\n")
+	require.NoError(t, err)
+
+	// Test: The event and a heartbeat arrive while the handler is still running
+	br := bufio.NewReader(conn)
+	var received strings.Builder
+	for !strings.Contains(received.String(), ": heartbeat\n") {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		received.WriteString(line)
+	}
+	assert.Contains(t, received.String(), "id: 2\ndata: resumed after 1\n\n")
+
+	// Test: Run returns once the client disconnects
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-stopped:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after client disconnect")
+	}
+}
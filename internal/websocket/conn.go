@@ -0,0 +1,304 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is a WebSocket frame opcode (RFC 6455 Section 5.2)
+type MessageType int
+
+const (
+	continuationFrame MessageType = 0
+
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+	CloseMessage  MessageType = 8
+	PingMessage   MessageType = 9
+	PongMessage   MessageType = 10
+)
+
+// Close status codes (RFC 6455 Section 7.4.1)
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+// closeTimeout bounds how long Close waits for the peer to answer a close frame
+const closeTimeout = 5 * time.Second
+
+// maxControlPayload is the largest payload allowed in a control frame
+const maxControlPayload = 125
+
+// ErrCloseSent is returned when writing after a close frame has been sent
+var ErrCloseSent = errors.New("websocket: close frame already sent")
+
+// CloseError is returned by ReadMessage once the connection has been closed,
+// carrying the close code and reason sent by the peer, or by us on a protocol violation
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// Conn is a WebSocket connection established by Upgrader.Upgrade
+type Conn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	isServer       bool
+	maxMessageSize int64
+	fragmentSize   int
+	subprotocol    string
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+// newConn wraps an upgraded connection, reading buffered before anything else from conn.
+// Servers expect masked frames and send unmasked ones, clients do the opposite
+func newConn(conn net.Conn, buffered []byte, isServer bool, maxMessageSize int64) *Conn {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	return &Conn{
+		conn:           conn,
+		br:             bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), conn)),
+		isServer:       isServer,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Subprotocol returns the negotiated subprotocol, or "" if none was agreed
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// NetConn returns the underlying connection, e.g. to set deadlines
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// ReadMessage reads the next complete text or binary message, reassembling fragments.
+// Pings are answered automatically and pongs are discarded. When a close frame arrives
+// it is answered, the connection is closed and a *CloseError is returned.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var msgType MessageType
+	var msg []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame(c.maxMessageSize - int64(len(msg)))
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				return 0, nil, c.fail(closeErr.Code, closeErr.Text)
+			}
+			_ = c.conn.Close()
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			err = c.WriteControl(PongMessage, payload)
+			if err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case continuationFrame:
+			if msgType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			if msgType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			msgType = opcode
+		}
+
+		msg = append(msg, payload...)
+		if !fin {
+			continue
+		}
+
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+		}
+		return msgType, msg, nil
+	}
+}
+
+// WriteMessage sends a text or binary message, fragmenting it if FragmentSize was set
+func (c *Conn) WriteMessage(msgType MessageType, data []byte) error {
+	if msgType != TextMessage && msgType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid data message type %d", msgType)
+	}
+	if msgType == TextMessage && !utf8.Valid(data) {
+		return fmt.Errorf("websocket: text message is not valid UTF-8")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	opcode := msgType
+	for {
+		chunk := data
+		if c.fragmentSize > 0 && len(chunk) > c.fragmentSize {
+			chunk = data[:c.fragmentSize]
+		}
+		data = data[len(chunk):]
+
+		fin := len(data) == 0
+		if err := c.writeFrame(fin, opcode, chunk); err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		opcode = continuationFrame
+	}
+}
+
+// WriteControl sends a ping or pong frame with a payload of at most 125 bytes
+func (c *Conn) WriteControl(msgType MessageType, data []byte) error {
+	if msgType != PingMessage && msgType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", msgType)
+	}
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("websocket: control frame payload exceeds %d bytes", maxControlPayload)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrame(true, msgType, data)
+}
+
+// WriteClose sends a close frame without waiting for the reply. Use it to close from
+// another goroutine while one is blocked in ReadMessage, which will then observe the reply
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if code == CloseNoStatusReceived {
+		payload = nil
+	}
+	if len(payload) > maxControlPayload {
+		return fmt.Errorf("websocket: close reason exceeds %d bytes", maxControlPayload-2)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	c.closeSent = true
+	return c.writeFrame(true, CloseMessage, payload)
+}
+
+// Close performs the closing handshake, sending a close frame and waiting briefly for the
+// peer's reply before closing the connection. It must not be called concurrently with ReadMessage.
+func (c *Conn) Close(code int, reason string) error {
+	err := c.WriteClose(code, reason)
+	if err != nil && !errors.Is(err, ErrCloseSent) {
+		_ = c.conn.Close()
+		return err
+	}
+
+	// Discard anything still in flight until the peer's close frame arrives
+	_ = c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		_, opcode, _, err := c.readFrame(c.maxMessageSize)
+		if err != nil || opcode == CloseMessage {
+			break
+		}
+	}
+	return c.conn.Close()
+}
+
+// handleClose answers a close frame from the peer and closes the connection
+func (c *Conn) handleClose(payload []byte) error {
+	code := CloseNoStatusReceived
+	var text string
+
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close frame payload")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in close reason")
+		}
+	}
+
+	// Echo the peer's code, unless this frame is the reply to our own close
+	_ = c.WriteClose(code, "")
+	_ = c.conn.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail closes the connection after a protocol violation, telling the peer why
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.WriteClose(code, reason)
+	_ = c.conn.Close()
+	return &CloseError{Code: code, Text: reason}
+}
+
+// validCloseCode reports whether a received close code may appear on the wire
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003:
+		return true
+	case code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	default:
+		return false
+	}
+}
+
+// newMaskKey returns a random masking key for client frames
+func newMaskKey() [4]byte {
+	var key [4]byte
+	_, _ = rand.Read(key[:])
+	return key
+}
+
+// maskBytes XORs b in place with the masking key (RFC 6455 Section 5.3)
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
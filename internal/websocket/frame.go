@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Frame header bits (RFC 6455 Section 5.2)
+const (
+	finBit      = 0x80
+	rsvBits     = 0x70
+	opcodeBits  = 0x0f
+	maskBit     = 0x80
+	lengthBits  = 0x7f
+	length16Bit = 126
+	length64Bit = 127
+)
+
+// readFrame reads a single frame, rejecting payloads larger than limit. Protocol
+// violations are reported as a *CloseError carrying the code to close with
+func (c *Conn) readFrame(limit int64) (bool, MessageType, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&finBit != 0
+	opcode := MessageType(header[0] & opcodeBits)
+	masked := header[1]&maskBit != 0
+	length := int64(header[1] & lengthBits)
+
+	if header[0]&rsvBits != 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "reserved bits set without extension"}
+	}
+
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "fragmented control frame"}
+		}
+		if length > maxControlPayload {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "control frame too large"}
+		}
+	default:
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "unknown opcode"}
+	}
+
+	if masked != c.isServer {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "invalid frame masking"}
+	}
+
+	switch length {
+	case length16Bit:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case length64Bit:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n>>63 != 0 {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "invalid payload length"}
+		}
+		length = int64(n)
+	}
+
+	// Control frames are capped separately so they can arrive in the middle of a large message
+	if opcode < CloseMessage && length > limit {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Text: "message too big"}
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single frame, masking it when acting as a client. Callers hold writeMu
+func (c *Conn) writeFrame(fin bool, opcode MessageType, payload []byte) error {
+	buf := make([]byte, 0, 14+len(payload))
+
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finBit
+	}
+	buf = append(buf, b0)
+
+	var b1 byte
+	if !c.isServer {
+		b1 = maskBit
+	}
+	switch {
+	case len(payload) < length16Bit:
+		buf = append(buf, b1|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		buf = append(buf, b1|length16Bit)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(payload)))
+	default:
+		buf = append(buf, b1|length64Bit)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(payload)))
+	}
+
+	if c.isServer {
+		buf = append(buf, payload...)
+	} else {
+		key := newMaskKey()
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(key, buf[start:])
+	}
+
+	_, err := c.conn.Write(buf)
+	return err
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// acceptGUID is appended to the client key when computing Sec-WebSocket-Accept (RFC 6455 Section 1.3)
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultMaxMessageSize is the message size limit used when Upgrader.MaxMessageSize is zero
+const DefaultMaxMessageSize = 1 << 20
+
+// Upgrader performs the opening handshake from inside a server.Handler
+type Upgrader struct {
+	// Subprotocols lists the supported subprotocols in order of preference
+	Subprotocols []string
+	// CheckOrigin decides whether to accept the request's Origin. When nil, requests
+	// with an Origin header are only accepted if its host matches the Host header
+	CheckOrigin func(req *request.Request) bool
+	// MaxMessageSize limits the size of a reassembled incoming message in bytes
+	MaxMessageSize int64
+	// FragmentSize splits outgoing data messages into frames of at most this many bytes, zero disables
+	FragmentSize int
+}
+
+// Upgrade validates the handshake, hijacks the connection and replies with 101 Switching Protocols.
+// On failure the returned HandlerError should be returned from the handler.
+func (u *Upgrader) Upgrade(w io.Writer, req *request.Request) (*Conn, *server.HandlerError) {
+	if req.RequestLine.Method != "GET" {
+		return nil, &server.HandlerError{StatusCode: 405, Message: "websocket: handshake must use GET\n"}
+	}
+	if !headerHasToken(req.Headers, "Connection", "upgrade") {
+		return nil, &server.HandlerError{StatusCode: 400, Message: "websocket: missing Connection: Upgrade\n"}
+	}
+	if !headerHasToken(req.Headers, "Upgrade", "websocket") {
+		return nil, &server.HandlerError{StatusCode: 400, Message: "websocket: missing Upgrade: websocket\n"}
+	}
+	if req.Headers.Get("Sec-WebSocket-Version") != "13" {
+		return nil, &server.HandlerError{StatusCode: 426, Message: "websocket: unsupported version, expected 13\n"}
+	}
+
+	key := req.Headers.Get("Sec-WebSocket-Key")
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 16 {
+		return nil, &server.HandlerError{StatusCode: 400, Message: "websocket: invalid Sec-WebSocket-Key\n"}
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		return nil, &server.HandlerError{StatusCode: 403, Message: "websocket: origin not allowed\n"}
+	}
+
+	hj, ok := w.(server.Hijacker)
+	if !ok {
+		return nil, &server.HandlerError{StatusCode: 500, Message: "websocket: response writer cannot be hijacked\n"}
+	}
+	netConn, buffered, err := hj.Hijack()
+	if err != nil {
+		return nil, &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("websocket: %v\n", err)}
+	}
+
+	h := headers.NewHeaders()
+	h["Upgrade"] = "websocket"
+	h["Connection"] = "Upgrade"
+	h["Sec-WebSocket-Accept"] = AcceptKey(key)
+	subprotocol := u.selectSubprotocol(req)
+	if subprotocol != "" {
+		h["Sec-WebSocket-Protocol"] = subprotocol
+	}
+
+	// The handler owns the connection now, so failures close it rather than returning an error response
+	err = response.WriteStatusLine(netConn, response.StatusSwitchingProtocols)
+	if err == nil {
+		err = response.WriteHeaders(netConn, h)
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, &server.HandlerError{StatusCode: 500, Message: fmt.Sprintf("websocket: error writing handshake: %v\n", err)}
+	}
+
+	c := newConn(netConn, buffered, true, u.MaxMessageSize)
+	c.subprotocol = subprotocol
+	c.fragmentSize = u.FragmentSize
+	return c, nil
+}
+
+// AcceptKey computes the Sec-WebSocket-Accept value for a Sec-WebSocket-Key
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// selectSubprotocol returns the first supported subprotocol offered by the client
+func (u *Upgrader) selectSubprotocol(req *request.Request) string {
+	offered := headerTokens(req.Headers, "Sec-WebSocket-Protocol")
+	for _, supported := range u.Subprotocols {
+		for _, p := range offered {
+			if p == supported {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin accepts requests without an Origin, or whose Origin host matches the Host header
+func sameOrigin(req *request.Request) bool {
+	origin := req.Headers.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Headers.Get("Host"))
+}
+
+// headerTokens splits a comma separated header value into trimmed tokens
+func headerTokens(h headers.Headers, key string) []string {
+	var tokens []string
+	for _, t := range strings.Split(h.Get(key), ",") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// headerHasToken reports whether a comma separated header contains token, case-insensitive
+func headerHasToken(h headers.Headers, key, token string) bool {
+	for _, t := range headerTokens(h, key) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"httpfromtcp/internal/request"
+	"net"
+	"strings"
+	"testing"
+)
+
+// hijackWriter is a response writer whose Hijack returns one end of a pipe
+type hijackWriter struct {
+	strings.Builder
+	conn net.Conn
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, []byte, error) {
+	return w.conn, nil, nil
+}
+
+const handshake = "GET /chat HTTP/1.1\r\n" +
+	"Host: example.com\r\n" +
+	"Upgrade: websocket\r\n" +
+	"Connection: keep-alive, Upgrade\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+	"Sec-WebSocket-Protocol: chat, superchat\r\n" +
+	"Sec-WebSocket-Version: 13\r\n" +
+	"Origin: http://example.com\r\n" +
+	"\r\n"
+
+func parse(t *testing.T, raw string) *request.Request {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	return req
+}
+
+// upgradePipe upgrades the server end of a pipe and returns both ends as Conns
+func upgradePipe(t *testing.T, u *Upgrader) (*Conn, *Conn, string) {
+	t.Helper()
+	serverEnd, clientEnd := net.Pipe()
+	t.Cleanup(func() {
+		_ = serverEnd.Close()
+		_ = clientEnd.Close()
+	})
+
+	// The handshake response has to be read while Upgrade writes it
+	respCh := make(chan string, 1)
+	br := bufio.NewReader(clientEnd)
+	go func() {
+		var resp strings.Builder
+		for {
+			line, err := br.ReadString('\n')
+			resp.WriteString(line)
+			if err != nil || line == "\r\n" {
+				break
+			}
+		}
+		respCh <- resp.String()
+	}()
+
+	server, herr := u.Upgrade(&hijackWriter{conn: serverEnd}, parse(t, handshake))
+	require.Nil(t, herr)
+	resp := <-respCh
+
+	client := newConn(clientEnd, nil, false, 0)
+	client.br = br
+	return server, client, resp
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Test: Sample from RFC 6455 Section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestUpgrade(t *testing.T) {
+	// Test: Successful handshake with subprotocol negotiation
+	u := &Upgrader{Subprotocols: []string{"superchat", "chat"}}
+	server, _, resp := upgradePipe(t, u)
+	assert.True(t, strings.HasPrefix(resp, "HTTP/1.1 101 Switching Protocols\r\n"))
+	assert.Contains(t, resp, "Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n")
+	assert.Contains(t, resp, "Sec-WebSocket-Protocol: superchat\r\n")
+	assert.Equal(t, "superchat", server.Subprotocol())
+
+	// Test: Missing upgrade header
+	_, herr := (&Upgrader{}).Upgrade(&hijackWriter{}, parse(t, strings.Replace(handshake, "Upgrade: websocket\r\n", "", 1)))
+	require.NotNil(t, herr)
+	assert.Equal(t, 400, herr.StatusCode)
+
+	// Test: Unsupported version
+	_, herr = (&Upgrader{}).Upgrade(&hijackWriter{}, parse(t, strings.Replace(handshake, "Version: 13", "Version: 8", 1)))
+	require.NotNil(t, herr)
+	assert.Equal(t, 426, herr.StatusCode)
+
+	// Test: Invalid key
+	_, herr = (&Upgrader{}).Upgrade(&hijackWriter{}, parse(t, strings.Replace(handshake, "dGhlIHNhbXBsZSBub25jZQ==", "short", 1)))
+	require.NotNil(t, herr)
+	assert.Equal(t, 400, herr.StatusCode)
+
+	// Test: Cross origin request is rejected by default
+	_, herr = (&Upgrader{}).Upgrade(&hijackWriter{}, parse(t, strings.Replace(handshake, "http://example.com", "http://evil.com", 1)))
+	require.NotNil(t, herr)
+	assert.Equal(t, 403, herr.StatusCode)
+
+	// Test: Wrong method
+	_, herr = (&Upgrader{}).Upgrade(&hijackWriter{}, parse(t, strings.Replace(handshake, "GET", "POST", 1)))
+	require.NotNil(t, herr)
+	assert.Equal(t, 405, herr.StatusCode)
+}
+
+func TestMessages(t *testing.T) {
+	server, client, _ := upgradePipe(t, &Upgrader{})
+	client.fragmentSize = 3
+
+	// Test: Fragmented text message is reassembled
+	go func() { _ = client.WriteMessage(TextMessage, []byte("hello world")) }()
+	msgType, data, err := server.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, msgType)
+	assert.Equal(t, "hello world", string(data))
+
+	// Test: Server to client binary message with 16 bit length
+	payload := []byte(strings.Repeat("x", 300))
+	go func() { _ = server.WriteMessage(BinaryMessage, payload) }()
+	msgType, data, err = client.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, msgType)
+	assert.Equal(t, payload, data)
+
+	// Test: Ping is answered with a pong carrying the same payload
+	go func() {
+		_ = client.WriteControl(PingMessage, []byte("are you there"))
+		_ = client.WriteMessage(TextMessage, []byte("after ping"))
+	}()
+	pong := make(chan []byte, 1)
+	go func() {
+		_, opcode, p, err := client.readFrame(125)
+		if err == nil && opcode == PongMessage {
+			pong <- p
+		}
+		close(pong)
+	}()
+	_, data, err = server.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "after ping", string(data))
+	assert.Equal(t, "are you there", string(<-pong))
+
+	// Test: Close handshake initiated by the server
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.ReadMessage()
+		done <- err
+	}()
+	require.NoError(t, server.Close(CloseNormalClosure, "bye"))
+	err = <-done
+	var closeErr *CloseError
+	require.True(t, errors.As(err, &closeErr))
+	assert.Equal(t, CloseNormalClosure, closeErr.Code)
+	assert.Equal(t, "bye", closeErr.Text)
+
+	// Test: Writes after close are rejected
+	assert.ErrorIs(t, server.WriteMessage(TextMessage, []byte("late")), ErrCloseSent)
+}
+
+func TestProtocolErrors(t *testing.T) {
+	// expectClose sends a message from the client and asserts the server closes with code
+	expectClose := func(u *Upgrader, code int, send func(client *Conn)) {
+		t.Helper()
+		server, client, _ := upgradePipe(t, u)
+		reply := make(chan error, 1)
+		go func() {
+			send(client)
+			_, _, err := client.ReadMessage()
+			reply <- err
+		}()
+
+		_, _, err := server.ReadMessage()
+		var closeErr *CloseError
+		require.True(t, errors.As(err, &closeErr))
+		assert.Equal(t, code, closeErr.Code)
+
+		// The client sees the close frame sent by the server
+		err = <-reply
+		require.True(t, errors.As(err, &closeErr))
+		assert.Equal(t, code, closeErr.Code)
+	}
+
+	// Test: Invalid UTF-8 in a text message
+	expectClose(&Upgrader{}, CloseInvalidFramePayloadData, func(client *Conn) {
+		client.writeMu.Lock()
+		defer client.writeMu.Unlock()
+		_ = client.writeFrame(true, TextMessage, []byte{0xff, 0xfe})
+	})
+
+	// Test: Message larger than the limit
+	expectClose(&Upgrader{MaxMessageSize: 4}, CloseMessageTooBig, func(client *Conn) {
+		_ = client.WriteMessage(BinaryMessage, []byte("too large"))
+	})
+
+	// Test: Unmasked frame from the client
+	expectClose(&Upgrader{}, CloseProtocolError, func(client *Conn) {
+		client.isServer = true
+		_ = client.WriteMessage(TextMessage, []byte("unmasked"))
+		client.isServer = false
+	})
+
+	// Test: Continuation without a preceding data frame
+	expectClose(&Upgrader{}, CloseProtocolError, func(client *Conn) {
+		client.writeMu.Lock()
+		defer client.writeMu.Unlock()
+		_ = client.writeFrame(true, continuationFrame, []byte("orphan"))
+	})
+
+	// Test: Peer initiated close is echoed
+	expectClose(&Upgrader{}, CloseGoingAway, func(client *Conn) {
+		_ = client.WriteClose(CloseGoingAway, "leaving")
+	})
+}